@@ -2,8 +2,33 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+const (
+	// DefaultQueryTimeout is used when the datasource does not configure a timeout
+	DefaultQueryTimeout = 60 * time.Second
+	// DefaultLiveTailInterval is how often a live tail polls OpenObserve by default
+	DefaultLiveTailInterval = 2 * time.Second
+	// DefaultLiveTailRateLimit is the default maximum number of rows per second published by a live tail
+	DefaultLiveTailRateLimit = 100
+	// DefaultPartitionMinRange is the time range above which searches are partitioned by default
+	DefaultPartitionMinRange = 24 * time.Hour
+	// DefaultMaxRows is the default maximum number of rows returned by a query
+	DefaultMaxRows = 100000
+	// DefaultCacheTTL is how long query results are cached by default
+	DefaultCacheTTL = time.Minute
+	// DefaultCacheMaxSize is the default size bound of the query result cache, in bytes of decoded hits
+	DefaultCacheMaxSize = 64 << 20
+	// DefaultIncrementalOverlap is the default part of the previous time range searched again by an incremental query
+	DefaultIncrementalOverlap = 10 * time.Minute
+	// DefaultMaxConcurrentQueries is the default number of requests a datasource sends to OpenObserve concurrently
+	DefaultMaxConcurrentQueries = 10
 )
 
 type PluginSettings struct {
@@ -14,10 +39,45 @@ type PluginSettings struct {
 }
 
 type JsonData struct {
+	Database             string `json:"database"`          // OpenObserve organization, named database by the SQL editor
+	AuthMode             string `json:"authMode"`          // basic, bearer or header, defaults to basic
+	TLSAuth              bool   `json:"tlsAuth"`           // present a client certificate (mutual TLS)
+	TLSAuthWithCACert    bool   `json:"tlsAuthWithCACert"` // verify the server against a custom CA bundle
+	TLSSkipVerify        bool   `json:"tlsSkipVerify"`
+	ServerName           string `json:"serverName"`           // overrides the server name used for certificate verification
+	Timeout              int    `json:"timeout"`              // default query timeout in seconds, from the standard HTTP settings
+	OauthPassThru        bool   `json:"oauthPassThru"`        // forward the Grafana user's OAuth identity to OpenObserve
+	RetryMaxAttempts     int    `json:"retryMaxAttempts"`     // attempts for transient errors, 1 disables retries, defaults to 3
+	LiveTailInterval     int    `json:"liveTailInterval"`     // seconds between two polls of a live tail
+	LiveTailRateLimit    int    `json:"liveTailRateLimit"`    // maximum number of rows per second published by a live tail
+	PartitionMinRange    int    `json:"partitionMinRange"`    // seconds of time range above which searches are partitioned
+	PartitionConcurrency int    `json:"partitionConcurrency"` // partitions of a search run concurrently
+	MaxRows              int    `json:"maxRows"`              // maximum number of rows returned by a query
+	DisableCache         bool   `json:"disableCache"`         // do not cache query results in the plugin
+	CacheTTL             int    `json:"cacheTTL"`             // seconds a query result is cached
+	CacheMaxSize         int    `json:"cacheMaxSize"`         // size bound of the query result cache in megabytes
+	IncrementalOverlap   int    `json:"incrementalOverlap"`   // seconds of the previous time range searched again by an incremental query
+	MaxConcurrentQueries int    `json:"maxConcurrentQueries"` // requests in flight to OpenObserve, whatever query sent them, negative for unlimited
+
+	// KeepCookies are the cookies Grafana forwards to OpenObserve, from the standard HTTP settings
+	KeepCookies []string `json:"keepCookies"`
+
+	// QueryTypeConcurrency bounds the requests in flight for the queries of a query type (logs,
+	// metrics, traces or fallback), within MaxConcurrentQueries
+	QueryTypeConcurrency map[string]int `json:"queryTypeConcurrency"`
+
+	DisableCircuitBreaker      bool `json:"disableCircuitBreaker"`      // keep sending requests to an unhealthy OpenObserve
+	CircuitBreakerFailures     int  `json:"circuitBreakerFailures"`     // consecutive failures suspending requests, defaults to 5
+	CircuitBreakerOpenDuration int  `json:"circuitBreakerOpenDuration"` // seconds requests are suspended before probing OpenObserve again
+	ServeStaleResults          bool `json:"serveStaleResults"`          // serve the last result of a query while requests are suspended
 }
 
 type DecryptedSecureJSONData struct {
-	Password string `json:"password"`
+	Password      string `json:"password"`
+	Token         string `json:"token"` // service account token used by the bearer and header auth modes
+	TLSCACert     string `json:"tlsCACert"`
+	TLSClientCert string `json:"tlsClientCert"`
+	TLSClientKey  string `json:"tlsClientKey"`
 }
 
 func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSettings, error) {
@@ -37,11 +97,197 @@ func LoadPluginSettings(source backend.DataSourceInstanceSettings) (*PluginSetti
 		settings.DecryptedSecureJSONData = loadSecretPluginSettings(source.DecryptedSecureJSONData)
 	}
 
+	if settings.JsonData.AuthMode == "" {
+		settings.JsonData.AuthMode = openobserve.AuthModeBasic // keep the behavior of datasources created before auth modes existed
+	}
+	switch settings.JsonData.AuthMode {
+	case openobserve.AuthModeBasic:
+	case openobserve.AuthModeBearer, openobserve.AuthModeHeader:
+		if settings.DecryptedSecureJSONData.Token == "" {
+			return nil, fmt.Errorf("auth mode %q requires a token", settings.JsonData.AuthMode)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported auth mode: %s", settings.JsonData.AuthMode)
+	}
+
+	if settings.JsonData.TLSAuth && (settings.DecryptedSecureJSONData.TLSClientCert == "" || settings.DecryptedSecureJSONData.TLSClientKey == "") {
+		return nil, fmt.Errorf("TLS client authentication requires a client certificate and key")
+	}
+	if settings.JsonData.TLSAuthWithCACert && settings.DecryptedSecureJSONData.TLSCACert == "" {
+		return nil, fmt.Errorf("TLS with CA certificate requires a CA certificate")
+	}
+
 	return &settings, nil
 }
 
+// ForwardHTTPHeaders reports whether the headers forwarded by Grafana with a request are sent along
+// to OpenObserve. Grafana only forwards the OAuth identity of the user with OAuth pass-through and
+// only the allowed cookies, either one turns the forwarding on.
+func (s *PluginSettings) ForwardHTTPHeaders() bool {
+	return s.JsonData.OauthPassThru || len(s.JsonData.KeepCookies) > 0
+}
+
+// Auth returns the credentials the OpenObserve client authenticates with
+func (s *PluginSettings) Auth() openobserve.Auth {
+	return openobserve.Auth{
+		Mode:     s.JsonData.AuthMode,
+		Username: s.Username,
+		Password: s.DecryptedSecureJSONData.Password,
+		Token:    s.DecryptedSecureJSONData.Token,
+
+		OAuthPassThru: s.JsonData.OauthPassThru,
+	}
+}
+
+// QueryTimeout returns the default timeout of OpenObserve requests
+func (s *PluginSettings) QueryTimeout() time.Duration {
+	if s.JsonData.Timeout > 0 {
+		return time.Duration(s.JsonData.Timeout) * time.Second
+	}
+	return DefaultQueryTimeout
+}
+
+// RetryPolicy returns the retry policy of the OpenObserve client
+func (s *PluginSettings) RetryPolicy() openobserve.RetryPolicy {
+	retryPolicy := openobserve.DefaultRetryPolicy
+	if s.JsonData.RetryMaxAttempts > 0 {
+		retryPolicy.MaxAttempts = s.JsonData.RetryMaxAttempts
+	}
+	return retryPolicy
+}
+
+// CircuitBreakerPolicy returns when the OpenObserve client suspends requests after repeated failures
+func (s *PluginSettings) CircuitBreakerPolicy() openobserve.CircuitBreakerPolicy {
+	if s.JsonData.DisableCircuitBreaker {
+		return openobserve.CircuitBreakerPolicy{}
+	}
+	circuitBreakerPolicy := openobserve.DefaultCircuitBreakerPolicy
+	if s.JsonData.CircuitBreakerFailures > 0 {
+		circuitBreakerPolicy.FailureThreshold = s.JsonData.CircuitBreakerFailures
+	}
+	if s.JsonData.CircuitBreakerOpenDuration > 0 {
+		circuitBreakerPolicy.OpenDuration = time.Duration(s.JsonData.CircuitBreakerOpenDuration) * time.Second
+	}
+	return circuitBreakerPolicy
+}
+
+// LiveTailInterval returns how often a live tail polls OpenObserve
+func (s *PluginSettings) LiveTailInterval() time.Duration {
+	if s.JsonData.LiveTailInterval > 0 {
+		return time.Duration(s.JsonData.LiveTailInterval) * time.Second
+	}
+	return DefaultLiveTailInterval
+}
+
+// LiveTailRateLimit returns the maximum number of rows per second published by a live tail
+func (s *PluginSettings) LiveTailRateLimit() int {
+	if s.JsonData.LiveTailRateLimit > 0 {
+		return s.JsonData.LiveTailRateLimit
+	}
+	return DefaultLiveTailRateLimit
+}
+
+// PartitionMinRange returns the time range above which searches are partitioned
+func (s *PluginSettings) PartitionMinRange() time.Duration {
+	if s.JsonData.PartitionMinRange > 0 {
+		return time.Duration(s.JsonData.PartitionMinRange) * time.Second
+	}
+	return DefaultPartitionMinRange
+}
+
+// PartitionConcurrency returns the number of partitions of a search run concurrently
+func (s *PluginSettings) PartitionConcurrency() int {
+	if s.JsonData.PartitionConcurrency > 0 {
+		return s.JsonData.PartitionConcurrency
+	}
+	return openobserve.DefaultPartitionConcurrency
+}
+
+// MaxRows returns the maximum number of rows returned by a query
+func (s *PluginSettings) MaxRows() int64 {
+	if s.JsonData.MaxRows > 0 {
+		return int64(s.JsonData.MaxRows)
+	}
+	return DefaultMaxRows
+}
+
+// CacheTTL returns how long query results are cached
+func (s *PluginSettings) CacheTTL() time.Duration {
+	if s.JsonData.CacheTTL > 0 {
+		return time.Duration(s.JsonData.CacheTTL) * time.Second
+	}
+	return DefaultCacheTTL
+}
+
+// CacheMaxSize returns the size bound of the query result cache in bytes
+func (s *PluginSettings) CacheMaxSize() int64 {
+	if s.JsonData.CacheMaxSize > 0 {
+		return int64(s.JsonData.CacheMaxSize) << 20
+	}
+	return DefaultCacheMaxSize
+}
+
+// IncrementalOverlap returns the part of the previous time range searched again by an incremental
+// query, to pick up data ingested late
+func (s *PluginSettings) IncrementalOverlap() time.Duration {
+	if s.JsonData.IncrementalOverlap > 0 {
+		return time.Duration(s.JsonData.IncrementalOverlap) * time.Second
+	}
+	return DefaultIncrementalOverlap
+}
+
+// MaxConcurrentQueries returns the number of requests the datasource sends to OpenObserve
+// concurrently, counting every partition, page and stream of a query, 0 if unlimited
+func (s *PluginSettings) MaxConcurrentQueries() int {
+	switch {
+	case s.JsonData.MaxConcurrentQueries > 0:
+		return s.JsonData.MaxConcurrentQueries
+	case s.JsonData.MaxConcurrentQueries < 0:
+		return 0 // unlimited
+	}
+	return DefaultMaxConcurrentQueries
+}
+
+// QueryTypeConcurrency returns the number of requests in flight for the queries of each query type,
+// query types without a positive limit are only bounded by MaxConcurrentQueries
+func (s *PluginSettings) QueryTypeConcurrency() map[string]int {
+	limits := make(map[string]int, len(s.JsonData.QueryTypeConcurrency))
+	for queryType, limit := range s.JsonData.QueryTypeConcurrency {
+		if limit > 0 {
+			limits[queryType] = limit
+		}
+	}
+	return limits
+}
+
+// TLSOptions returns the TLS options of the OpenObserve HTTP client, nil if none are configured.
+// Unlike the SDK defaults, a server name override is honored on its own.
+func (s *PluginSettings) TLSOptions() *httpclient.TLSOptions {
+	jsonData, secure := s.JsonData, s.DecryptedSecureJSONData
+	if !jsonData.TLSAuth && !jsonData.TLSAuthWithCACert && !jsonData.TLSSkipVerify && jsonData.ServerName == "" {
+		return nil
+	}
+
+	tlsOptions := &httpclient.TLSOptions{
+		ServerName:         jsonData.ServerName,
+		InsecureSkipVerify: jsonData.TLSSkipVerify,
+	}
+	if jsonData.TLSAuthWithCACert {
+		tlsOptions.CACertificate = secure.TLSCACert
+	}
+	if jsonData.TLSAuth {
+		tlsOptions.ClientCertificate = secure.TLSClientCert
+		tlsOptions.ClientKey = secure.TLSClientKey
+	}
+	return tlsOptions
+}
+
 func loadSecretPluginSettings(source map[string]string) *DecryptedSecureJSONData {
 	return &DecryptedSecureJSONData{
-		Password: source["password"],
+		Password:      source["password"],
+		Token:         source["token"],
+		TLSCACert:     source["tlsCACert"],
+		TLSClientCert: source["tlsClientCert"],
+		TLSClientKey:  source["tlsClientKey"],
 	}
 }
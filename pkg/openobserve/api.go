@@ -36,6 +36,8 @@ type Query struct {
 	EndTime   int64  `json:"end_time"`
 	From      int64  `json:"from"`
 	Size      int64  `json:"size"`
+
+	TrackTotalHits bool `json:"track_total_hits,omitempty"` // count all the matching hits in Total
 }
 
 // SearchResponse defines the structure of the OpenObserve search response
@@ -91,3 +93,40 @@ type Schema struct {
 	Name string `json:"name"`
 	Type string `json:"type"`
 }
+
+// ServerConfig defines the subset of the OpenObserve /config response used by the plugin
+type ServerConfig struct {
+	Version    string `json:"version"`
+	CommitHash string `json:"commit_hash"`
+	BuildDate  string `json:"build_date"`
+}
+
+// ListOrganizationsResponse defines the response structure for listing OpenObserve organizations
+type ListOrganizationsResponse struct {
+	Data []OrganizationInfo `json:"data"`
+}
+
+// OrganizationInfo defines the structure of each organization the user belongs to
+type OrganizationInfo struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+}
+
+// SearchPartitionRequestBody defines the body of the OpenObserve search partition request
+type SearchPartitionRequestBody struct {
+	Sql       string `json:"sql"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+}
+
+// SearchPartitionResponse defines the structure of the OpenObserve search partition response
+type SearchPartitionResponse struct {
+	TraceID           string     `json:"trace_id"`
+	FileNum           int        `json:"file_num"`
+	Records           int        `json:"records"`
+	OriginalSize      int        `json:"original_size"`
+	CompressedSize    int        `json:"compressed_size"`
+	HistogramInterval int64      `json:"histogram_interval"`
+	Partitions        [][2]int64 `json:"partitions"` // [start_time, end_time] in microseconds, in the order of the results
+	OrderBy           string     `json:"order_by"`
+}
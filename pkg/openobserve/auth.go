@@ -0,0 +1,117 @@
+package openobserve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	AuthModeBasic  = "basic"  // username and password
+	AuthModeBearer = "bearer" // service account token sent as "Authorization: Bearer <token>"
+	AuthModeHeader = "header" // token sent verbatim as the Authorization header value
+)
+
+// forwardedIdentityHeaders are the headers carrying the Grafana user's OAuth identity
+var forwardedIdentityHeaders = []string{
+	backend.OAuthIdentityTokenHeaderName,
+	backend.OAuthIdentityIDTokenHeaderName,
+}
+
+// forwardedCredentialHeaders are the headers forwarded by Grafana which may authenticate a request
+var forwardedCredentialHeaders = []string{
+	backend.OAuthIdentityTokenHeaderName,
+	backend.OAuthIdentityIDTokenHeaderName,
+	backend.CookiesHeaderName,
+}
+
+// Auth holds the credentials used to authenticate requests to the OpenObserve API
+type Auth struct {
+	Mode     string
+	Username string
+	Password string
+	Token    string
+	// OAuthPassThru forwards the OAuth identity of the Grafana user instead of the credentials above
+	// whenever the incoming request carries one
+	OAuthPassThru bool
+}
+
+// apply sets the Authorization header of req according to the auth mode
+func (a *Auth) apply(req *http.Request) {
+	switch a.Mode {
+	case AuthModeBearer:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.Token))
+	case AuthModeHeader:
+		req.Header.Set("Authorization", a.Token)
+	default:
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// WithForwardedHeaders returns a client that authenticates as the Grafana user identified by the
+// forwarded OAuth headers. The configured credentials are used instead when OAuth pass-through is
+// disabled or when no identity is present, e.g. for alerting queries. The returned client also
+// tells apart the credentials forwarded along with its requests, see Identity.
+func (c *OpenObserveClient) WithForwardedHeaders(headers http.Header) *OpenObserveClient {
+	client := *c
+	client.identity = c.forwardedIdentity(headers)
+	if c.auth.OAuthPassThru && headers.Get(backend.OAuthIdentityTokenHeaderName) != "" {
+		client.forwardedHeaders = make(http.Header, len(forwardedIdentityHeaders))
+		for _, key := range forwardedIdentityHeaders {
+			if value := headers.Get(key); value != "" {
+				client.forwardedHeaders.Set(key, value)
+			}
+		}
+	}
+	if client.identity == "" {
+		return c
+	}
+	return &client
+}
+
+// forwardedIdentity hashes the credentials among headers which reach OpenObserve: the OAuth identity
+// with OAuth pass-through, and every forwarded credential header, cookies included, when the HTTP
+// client forwards the headers of the Grafana request. An empty string is returned if there is none.
+func (c *OpenObserveClient) forwardedIdentity(headers http.Header) string {
+	var keys []string
+	switch {
+	case c.forwardHTTPHeaders:
+		keys = forwardedCredentialHeaders
+	case c.auth.OAuthPassThru && headers.Get(backend.OAuthIdentityTokenHeaderName) != "":
+		keys = forwardedIdentityHeaders
+	}
+
+	h := sha256.New()
+	forwarded := false
+	for _, key := range keys {
+		if value := headers.Get(key); value != "" {
+			fmt.Fprintf(h, "%s: %s\n", key, value)
+			forwarded = true
+		}
+	}
+	if !forwarded {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Identity identifies the credentials the requests of the client are sent with: a hash of the
+// forwarded user credentials, or an empty string for the configured credentials shared by every
+// Grafana user. Results obtained by clients of different identities must not be shared.
+func (c *OpenObserveClient) Identity() string {
+	return c.identity
+}
+
+// setAuthHeaders authenticates req with the forwarded user identity if any, otherwise with the configured credentials
+func (c *OpenObserveClient) setAuthHeaders(req *http.Request) {
+	if c.forwardedHeaders != nil {
+		for key := range c.forwardedHeaders {
+			req.Header.Set(key, c.forwardedHeaders.Get(key))
+		}
+		return
+	}
+	c.auth.apply(req)
+}
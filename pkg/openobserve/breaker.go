@@ -0,0 +1,137 @@
+package openobserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// ErrCircuitOpen is returned without contacting OpenObserve while the circuit breaker is open
+var ErrCircuitOpen = errors.New("OpenObserve is unavailable: requests are suspended after repeated failures")
+
+// CircuitBreakerPolicy configures when the circuit breaker of the client opens
+type CircuitBreakerPolicy struct {
+	FailureThreshold int           // consecutive failures opening the breaker, 0 disables the breaker
+	OpenDuration     time.Duration // time the breaker stays open before probing OpenObserve again
+}
+
+// DefaultCircuitBreakerPolicy is used when the datasource does not configure the circuit breaker
+var DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+}
+
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // requests are sent
+	circuitOpen                         // requests fail fast
+	circuitHalfOpen                     // a single probe request is sent, the others fail fast
+)
+
+// requestOutcome is the result of a request as seen by the circuit breaker
+type requestOutcome int
+
+const (
+	outcomeSuccess requestOutcome = iota
+	outcomeFailure                // OpenObserve failed, timed out or could not be reached
+	outcomeIgnored                // the request was cancelled by the caller
+)
+
+// circuitBreaker stops sending requests to an unhealthy OpenObserve, so that panels fail fast
+// instead of waiting for their timeout and adding load to an overloaded server
+type circuitBreaker struct {
+	policy CircuitBreakerPolicy
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the breaker last opened
+	probing  bool      // a half-open probe is in flight
+}
+
+func newCircuitBreaker(policy CircuitBreakerPolicy) *circuitBreaker {
+	return &circuitBreaker{policy: policy}
+}
+
+// allow reports whether a request may be sent. The returned function must be called with the
+// outcome of the request.
+func (b *circuitBreaker) allow() (done func(requestOutcome), err error) {
+	if b.policy.FailureThreshold <= 0 {
+		return func(requestOutcome) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == circuitOpen {
+		if remaining := b.policy.OpenDuration - time.Since(b.openedAt); remaining > 0 {
+			return nil, fmt.Errorf("%w, retrying in %s", ErrCircuitOpen, remaining.Round(time.Second))
+		}
+		b.state = circuitHalfOpen
+	}
+	if b.state == circuitHalfOpen {
+		if b.probing {
+			return nil, fmt.Errorf("%w, probing OpenObserve", ErrCircuitOpen)
+		}
+		b.probing = true
+		return b.probeDone, nil
+	}
+	return b.done, nil
+}
+
+// done records the outcome of a request sent while the breaker is closed
+func (b *circuitBreaker) done(outcome requestOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch outcome {
+	case outcomeSuccess:
+		b.failures = 0
+	case outcomeFailure:
+		b.failures++
+		if b.state == circuitClosed && b.failures >= b.policy.FailureThreshold {
+			log.DefaultLogger.Warn("Circuit breaker opened, OpenObserve requests are suspended", "failures", b.failures, "openDuration", b.policy.OpenDuration)
+			b.open()
+		}
+	}
+}
+
+// probeDone records the outcome of a half-open probe
+func (b *circuitBreaker) probeDone(outcome requestOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch outcome {
+	case outcomeSuccess:
+		log.DefaultLogger.Info("Circuit breaker closed, OpenObserve recovered")
+		b.state, b.failures = circuitClosed, 0
+	case outcomeFailure:
+		b.open()
+	}
+	// an ignored probe lets the next request probe
+}
+
+// open opens the breaker, b.mu must be held
+func (b *circuitBreaker) open() {
+	b.state, b.openedAt, b.failures = circuitOpen, time.Now(), 0
+}
+
+// classifyOutcome tells the circuit breaker whether a request failed because of OpenObserve:
+// network errors, timeouts, overload and server errors. Client errors such as an invalid SQL are
+// successes as far as the health of OpenObserve is concerned.
+func classifyOutcome(ctx context.Context, resp *http.Response, err error) requestOutcome {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return outcomeIgnored
+	}
+	if err != nil {
+		return outcomeFailure
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
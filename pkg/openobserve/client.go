@@ -1,68 +1,191 @@
 package openobserve
 
 import (
-	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/bytedance/sonic"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultTimeout is the deadline of requests which do not carry their own timeout when the client
+// options do not set one, no request to OpenObserve is sent without a deadline
+const defaultTimeout = 60 * time.Second
+
+// searchTimeoutGrace is added to the search timeout sent to OpenObserve to get the request deadline
+const searchTimeoutGrace = 5 * time.Second
+
 // OpenObserveClient is a client for interacting with the OpenObserve API
 type OpenObserveClient struct {
-	BaseUrl    string
-	username   string
-	password   string
-	httpClient *http.Client
+	BaseUrl          string
+	auth             Auth
+	forwardedHeaders http.Header // identity of the Grafana user, see WithForwardedHeaders
+	httpClient       *http.Client
+	retryPolicy      RetryPolicy
+	timeout          time.Duration
+	coalescer        *searchCoalescer // shared by the clients returned by WithForwardedHeaders
+	breaker          *circuitBreaker  // shared by the clients returned by WithForwardedHeaders
+	limiter          *requestLimiter  // shared by the clients returned by WithForwardedHeaders
+
+	identity           string // hash of the forwarded credentials, see Identity
+	forwardHTTPHeaders bool   // the HTTP client forwards the headers of the Grafana request
+}
+
+// ClientOptions configures an OpenObserveClient
+type ClientOptions struct {
+	Auth        Auth
+	HTTPClient  *http.Client  // carries the transport level settings (TLS, proxy, middlewares)
+	RetryPolicy RetryPolicy   // how transient errors are retried
+	Timeout     time.Duration // deadline of requests which do not carry their own timeout, defaultTimeout if not set
+
+	CircuitBreaker     CircuitBreakerPolicy // when requests are suspended after repeated failures
+	ForwardHTTPHeaders bool                 // HTTPClient forwards the headers of the Grafana request, e.g. cookies
+
+	MaxConcurrentRequests int            // requests in flight to OpenObserve, unlimited if not positive
+	QueryTypeConcurrency  map[string]int // requests in flight by query type, within MaxConcurrentRequests
 }
 
-// NewOpenObserveClient creates a new OpenObserve client with the given base URL, username, and password
-func NewOpenObserveClient(baseUrl, username, password string) *OpenObserveClient {
+// NewOpenObserveClient creates a new OpenObserve client with the given base URL and options
+func NewOpenObserveClient(baseUrl string, opts ClientOptions) *OpenObserveClient {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
 	return &OpenObserveClient{
-		BaseUrl:  baseUrl,
-		username: username,
-		password: password,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second, // Set request timeout to 60 seconds
-		},
+		BaseUrl:     baseUrl,
+		auth:        opts.Auth,
+		httpClient:  opts.HTTPClient,
+		retryPolicy: opts.RetryPolicy,
+		timeout:     opts.Timeout,
+		coalescer:   newSearchCoalescer(),
+		breaker:     newCircuitBreaker(opts.CircuitBreaker),
+		limiter:     newRequestLimiter(opts.MaxConcurrentRequests, opts.QueryTypeConcurrency),
+
+		forwardHTTPHeaders: opts.ForwardHTTPHeaders,
 	}
 }
 
-// Search performs a search request to the OpenObserve API
-func (c *OpenObserveClient) Search(searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*SearchResponse, error) {
+// ConcurrencyLimit returns the number of requests of queryType the client sends concurrently,
+// 0 if unlimited
+func (c *OpenObserveClient) ConcurrencyLimit(queryType string) int {
+	return c.limiter.limit(queryType)
+}
+
+// withTimeout bounds ctx by timeout, or by the client default timeout if timeout is not positive.
+// An earlier deadline of ctx always wins. The HTTP client has no timeout of its own, every request
+// must be sent with a context derived by withTimeout.
+func (c *OpenObserveClient) withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = c.timeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Search performs a search request to the OpenObserve API. The search is tagged with a trace id
+// so that it can be cancelled on the OpenObserve side when ctx is cancelled before it completes.
+// Identical concurrent searches run as the same identity share a single request, which is cancelled
+// once all of their contexts are.
+func (c *OpenObserveClient) Search(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*SearchResponse, error) {
+	ctx, span := startSearchSpan(ctx, "OpenObserveClient.Search", searchReqParam, searchReqBody)
+	searchResponse, err := c.coalescedSearch(ctx, searchReqParam, searchReqBody)
+	endSearchSpan(span, searchResponse, err)
+	return searchResponse, err
+}
+
+func (c *OpenObserveClient) coalescedSearch(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*SearchResponse, error) {
+	key, err := c.searchKey(searchReqParam, searchReqBody)
+	if err != nil {
+		return c.search(ctx, searchReqParam, searchReqBody, nil)
+	}
+	// the shared search may outlive the caller, which owns the request
+	sharedReqParam, sharedReqBody := *searchReqParam, *searchReqBody
+	return c.coalescer.do(ctx, key, func(ctx context.Context) (*SearchResponse, error) {
+		return c.search(ctx, &sharedReqParam, &sharedReqBody, nil)
+	})
+}
+
+// SearchStream performs a search through the OpenObserve _search_stream API and passes the partial
+// results to handler as they arrive, instead of accumulating the hits. The returned response
+// carries the merged metadata of the search but no hits. As for Search, the search is cancelled
+// on the OpenObserve side when ctx is cancelled before it completes.
+func (c *OpenObserveClient) SearchStream(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody, handler SearchStreamHandler) (*SearchResponse, error) {
+	streamReqParam := *searchReqParam
+	streamReqParam.EnableSSE = true
+	ctx, span := startSearchSpan(ctx, "OpenObserveClient.SearchStream", &streamReqParam, searchReqBody)
+	searchResponse, err := c.search(ctx, &streamReqParam, searchReqBody, handler)
+	endSearchSpan(span, searchResponse, err)
+	return searchResponse, err
+}
+
+// search tags the search with a trace id of its own, cancels it on the OpenObserve side when ctx is
+// done before it completes and sends it with the regular or the SSE API. The trace id is recorded
+// on the span of ctx, which links the Grafana trace to the OpenObserve query traces.
+func (c *OpenObserveClient) search(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody, handler SearchStreamHandler) (*SearchResponse, error) {
+	// OpenObserve stops the search after searchReqBody.Timeout, give its timeout error a moment to arrive
+	// before giving up on the request
+	var timeout time.Duration
+	if searchReqBody.Timeout > 0 {
+		timeout = time.Duration(searchReqBody.Timeout)*time.Second + searchTimeoutGrace
+	}
+	ctx, cancel := c.withTimeout(ctx, timeout)
+	defer cancel()
+
+	// every search gets its own trace id, the searches sharing a Grafana trace are cancelled one by one
+	traceID := newTraceID()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(TraceIDAttribute, traceID))
+	ctx = WithLogAttributes(ctx, "openobserveTraceId", traceID)
+	stop := c.cancelOnDone(ctx, searchReqParam.Organization, traceID)
+	defer stop()
+
+	searchResponse, err := c.send(ctx, traceID, searchReqParam, searchReqBody, handler)
+	if err != nil {
+		return nil, err
+	}
+	if searchResponse.TraceID == "" {
+		searchResponse.TraceID = traceID
+	}
+	return searchResponse, nil
+}
+
+func (c *OpenObserveClient) send(ctx context.Context, traceID string, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody, handler SearchStreamHandler) (*SearchResponse, error) {
 
 	// handle SSE request
 	if searchReqParam.EnableSSE {
-		req, err := c.newSSESearchRequest(searchReqParam, searchReqBody)
-		if err != nil {
-			return nil, err
-		}
-
-		log.DefaultLogger.Debug("http SSE request created", "request", req)
+		resp, err := c.doWithRetry(ctx, func() (*http.Request, error) {
+			req, err := c.newSSESearchRequest(ctx, searchReqParam, searchReqBody)
+			if err != nil {
+				return nil, err
+			}
+			setTraceparent(ctx, req, traceID)
 
-		resp, err := c.httpClient.Do(req)
+			Logger(ctx).Debug("http SSE request created", requestLogAttributes(req)...)
+			return req, nil
+		})
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
 
-		return handleSSEResponse(resp)
+		return handleSSEResponse(ctx, resp, handler)
 	}
 
 	// handle regular HTTP request
-	req, err := c.newSearchRequest(searchReqParam, searchReqBody)
-	if err != nil {
-		return nil, err
-	}
-
-	log.DefaultLogger.Debug("http request created", "request", req)
+	resp, err := c.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := c.newSearchRequest(ctx, searchReqParam, searchReqBody)
+		if err != nil {
+			return nil, err
+		}
+		setTraceparent(ctx, req, traceID)
 
-	resp, err := c.httpClient.Do(req)
+		Logger(ctx).Debug("http request created", requestLogAttributes(req)...)
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +197,7 @@ func (c *OpenObserveClient) Search(searchReqParam *SearchRequestParam, searchReq
 func handleRegularResponse(resp *http.Response) (*SearchResponse, error) {
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("http response status code: %d, body: %s", resp.StatusCode, string(respBody))
+		return nil, newAPIError(resp)
 	}
 
 	var searchResponse SearchResponse
@@ -83,49 +205,90 @@ func handleRegularResponse(resp *http.Response) (*SearchResponse, error) {
 	if err := decoder.Decode(&searchResponse); err != nil {
 		return nil, err
 	}
-	log.DefaultLogger.Debug("Regular", "len(searchResponse.Hits)", len(searchResponse.Hits))
+	Logger(resp.Request.Context()).Debug("Regular", "len(searchResponse.Hits)", len(searchResponse.Hits))
+	hitsDecoded.WithLabelValues(QueryTypeFromContext(resp.Request.Context())).Add(float64(len(searchResponse.Hits)))
 	return &searchResponse, nil
 }
 
-func handleSSEResponse(resp *http.Response) (*SearchResponse, error) {
+// handleSSEResponse reads the events of a _search_stream response until OpenObserve signals the end
+// of the search. The metadata of every partition is merged into the returned response, error and
+// cancel events are reported as errors. Hits are passed to handler if set, accumulated otherwise.
+func handleSSEResponse(ctx context.Context, resp *http.Response, handler SearchStreamHandler) (*SearchResponse, error) {
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http response status code: %d", resp.StatusCode)
+		return nil, newAPIError(resp)
 	}
 
 	var searchResponse SearchResponse
-	reader := bufio.NewReader(resp.Body)
+	var progress int // completion percentage reported by OpenObserve
+	decoder := newSSEDecoder(resp.Body)
 	for {
-		line, err := reader.ReadString('\n')
+		// the request shares ctx, so a cancellation closes the connection and unblocks the read below,
+		// report the cancellation rather than the resulting read error
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		event, err := decoder.Next()
 		if err != nil {
 			if err == io.EOF {
-				break
+				// older OpenObserve versions close the stream without an end event
+				return &searchResponse, nil
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
 			}
 			return nil, err
 		}
-		if strings.HasPrefix(line, "event: search_response_hits") {
-			line, err := reader.ReadBytes('\n')
+
+		switch event.Event {
+		case sseEventHits:
+			hits, err := decodeHits(event.Data)
 			if err != nil {
-				if err == io.EOF {
-					break
-				}
 				return nil, err
 			}
-			hits := bytes.TrimPrefix(line, []byte("data: "))
-			var partSearchResp SearchResponse
-			decoder := sonic.ConfigDefault.NewDecoder(bytes.NewBuffer(hits))
-			if err := decoder.Decode(&partSearchResp); err != nil {
+			Logger(ctx).Debug("SSE", "len(partSearchResp.Hits)", len(hits))
+			hitsDecoded.WithLabelValues(QueryTypeFromContext(ctx)).Add(float64(len(hits)))
+			if handler == nil {
+				searchResponse.Hits = append(searchResponse.Hits, hits...)
+				continue
+			}
+			if err := handler(&SearchStreamEvent{Hits: hits, Progress: progress, Metadata: &searchResponse}); err != nil {
+				return nil, err
+			}
+		case sseEventMetadata:
+			metadata, err := decodeMetadata(event.Data)
+			if err != nil {
 				return nil, err
 			}
-			log.DefaultLogger.Debug("SSE", "len(partSearchResp.Hits)", len(partSearchResp.Hits))
-			searchResponse.Hits = append(searchResponse.Hits, partSearchResp.Hits...)
+			searchResponse.mergeMetadata(metadata)
+		case sseEventProgress:
+			var searchProgress searchStreamProgress
+			if err := sonic.UnmarshalString(event.Data, &searchProgress); err != nil {
+				continue
+			}
+			Logger(ctx).Debug("SSE progress", "percent", searchProgress.Percent)
+			progress = searchProgress.Percent
+			if handler != nil {
+				if err := handler(&SearchStreamEvent{Progress: progress, Metadata: &searchResponse}); err != nil {
+					return nil, err
+				}
+			}
+		case sseEventError:
+			apiErr := decodeStreamError(event.Data)
+			Logger(ctx).Debug("SSE error", "error", apiErr)
+			return nil, apiErr
+		case sseEventCancel:
+			return nil, ErrSearchCancelled
+		case sseEventEnd:
+			return &searchResponse, nil
+		default:
+			Logger(ctx).Debug("SSE event ignored", "event", event.Event)
 		}
 	}
-	return &searchResponse, nil
 }
 
 // newSearchRequest creates a new HTTP request for the search operation
-func (c *OpenObserveClient) newSearchRequest(searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*http.Request, error) {
-	log.DefaultLogger.Debug("newSearchRequest called", "searchReqParam", searchReqParam, "searchReqBody", searchReqBody)
+func (c *OpenObserveClient) newSearchRequest(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*http.Request, error) {
+	Logger(ctx).Debug("newSearchRequest called", "searchReqParam", searchReqParam, "searchReqBody", searchReqBody)
 	searchReqBodyBytes, err := sonic.Marshal(searchReqBody)
 	if err != nil {
 		return nil, err
@@ -135,7 +298,7 @@ func (c *OpenObserveClient) newSearchRequest(searchReqParam *SearchRequestParam,
 	searchUrl := fmt.Sprintf("%s/api/%s/_search", c.BaseUrl, searchReqParam.Organization)
 
 	// create a new HTTP POST request with basic info
-	req, err := http.NewRequest(http.MethodPost, searchUrl, bytes.NewBuffer(searchReqBodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, searchUrl, bytes.NewBuffer(searchReqBodyBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -150,15 +313,14 @@ func (c *OpenObserveClient) newSearchRequest(searchReqParam *SearchRequestParam,
 
 	// set http headers
 	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(c.username, c.password)
-	// req.Header.Set("Authorization", fmt.Sprintf("Basic %s", c.BasicAuth))
+	c.setAuthHeaders(req)
 
 	return req, nil
 }
 
 // newSearchRequest creates a new HTTP request for the search operation
-func (c *OpenObserveClient) newSSESearchRequest(searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*http.Request, error) {
-	log.DefaultLogger.Debug("newSSESearchRequest called", "searchReqParam", searchReqParam, "searchReqBody", searchReqBody)
+func (c *OpenObserveClient) newSSESearchRequest(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*http.Request, error) {
+	Logger(ctx).Debug("newSSESearchRequest called", "searchReqParam", searchReqParam, "searchReqBody", searchReqBody)
 	searchReqBodyBytes, err := sonic.Marshal(searchReqBody)
 	if err != nil {
 		return nil, err
@@ -168,7 +330,7 @@ func (c *OpenObserveClient) newSSESearchRequest(searchReqParam *SearchRequestPar
 	searchUrl := fmt.Sprintf("%s/api/%s/_search_stream", c.BaseUrl, searchReqParam.Organization)
 
 	// create a new HTTP POST request with basic info
-	req, err := http.NewRequest(http.MethodPost, searchUrl, bytes.NewBuffer(searchReqBodyBytes))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, searchUrl, bytes.NewBuffer(searchReqBodyBytes))
 	if err != nil {
 		return nil, err
 	}
@@ -184,29 +346,33 @@ func (c *OpenObserveClient) newSSESearchRequest(searchReqParam *SearchRequestPar
 	// set http headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
-	req.SetBasicAuth(c.username, c.password)
+	c.setAuthHeaders(req)
 
 	return req, nil
 }
 
 // HealthCheck checks the health of the OpenObserve cluster
-func (c *OpenObserveClient) HealthCheck() error {
-	clusterUrl := fmt.Sprintf("%s/api/clusters", c.BaseUrl)
+func (c *OpenObserveClient) HealthCheck(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
 
-	req, err := http.NewRequest(http.MethodGet, clusterUrl, nil)
-	if err != nil {
-		return err
-	}
-	req.SetBasicAuth(c.username, c.password)
+	clusterUrl := fmt.Sprintf("%s/api/clusters", c.BaseUrl)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, clusterUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.setAuthHeaders(req)
+		return req, nil
+	})
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("health check failed: %d:%s", resp.StatusCode, resp.Status)
+		return fmt.Errorf("health check failed: %w", newAPIError(resp))
 	}
 
 	return nil
@@ -214,7 +380,7 @@ func (c *OpenObserveClient) HealthCheck() error {
 
 // HandleListStreams handles the HTTP request to list streams
 func (c *OpenObserveClient) HandleListStreams(rw http.ResponseWriter, req *http.Request) {
-	log.DefaultLogger.Debug("HandleListStreams called")
+	Logger(req.Context()).Debug("HandleListStreams called")
 
 	// initialize with the default values
 	listRequestParam := &ListStreamRequestParam{
@@ -232,11 +398,16 @@ func (c *OpenObserveClient) HandleListStreams(rw http.ResponseWriter, req *http.
 		listRequestParam.StreamType = streamType
 	}
 
-	listStreamResp, err := c.ListStreams(listRequestParam)
+	listStreamResp, err := c.WithForwardedHeaders(req.Header).ListStreams(req.Context(), listRequestParam)
 	if err != nil {
+		statusCode := http.StatusInternalServerError
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			statusCode = apiErr.StatusCode // e.g. let the frontend tell missing permissions apart
+		}
 		rw.Header().Set("Content-Type", "application/json")
-		rw.WriteHeader(http.StatusInternalServerError)
-		rw.Write([]byte(fmt.Sprintf(`{"error": "failed to list streams: %s"}`, err.Error())))
+		rw.WriteHeader(statusCode)
+		sonic.ConfigDefault.NewEncoder(rw).Encode(map[string]string{"error": fmt.Sprintf("failed to list streams: %s", err.Error())})
 		return
 	}
 
@@ -258,36 +429,49 @@ func (c *OpenObserveClient) HandleListStreams(rw http.ResponseWriter, req *http.
 }
 
 // ListStreams lists the streams information in the OpenObserve cluster
-func (c *OpenObserveClient) ListStreams(listStreamReqParam *ListStreamRequestParam) (*ListStreamResponse, error) {
+func (c *OpenObserveClient) ListStreams(ctx context.Context, listStreamReqParam *ListStreamRequestParam) (_ *ListStreamResponse, err error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "OpenObserveClient.ListStreams", trace.WithAttributes(
+		attribute.String("openobserve.organization", listStreamReqParam.Organization),
+		attribute.String("openobserve.stream_type", listStreamReqParam.StreamType),
+	))
+	defer func() {
+		if err != nil {
+			tracing.Error(span, err)
+		}
+		span.End()
+	}()
+
+	ctx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+
 	// Implement the logic to list streams here.
 	// construct the search URL
 	listStreamUrl := fmt.Sprintf("%s/api/%s/streams", c.BaseUrl, listStreamReqParam.Organization)
-	req, err := http.NewRequest(http.MethodGet, listStreamUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	q := req.URL.Query()
-	q.Set("type", listStreamReqParam.StreamType)
-	q.Set("sort", listStreamReqParam.SortBy)
-	q.Set("asc", fmt.Sprintf("%t", listStreamReqParam.Ascending))
-	q.Set("fetchSchema", "true") // always fetch schema information
-
-	req.URL.RawQuery = q.Encode()
-
-	// set http headers
-	// req.Header.Set("Accept", "application/json")
-	// req.Header.Set("Authorization", fmt.Sprintf("Basic %s", c.BasicAuth))
-	req.SetBasicAuth(c.username, c.password)
-
-	// fmt.Printf("req.URL.String(): %v\n", req.URL.String())
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, listStreamUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		q := req.URL.Query()
+		q.Set("type", listStreamReqParam.StreamType)
+		q.Set("sort", listStreamReqParam.SortBy)
+		q.Set("asc", fmt.Sprintf("%t", listStreamReqParam.Ascending))
+		q.Set("fetchSchema", "true") // always fetch schema information
+
+		req.URL.RawQuery = q.Encode()
+
+		// set http headers
+		// req.Header.Set("Accept", "application/json")
+		c.setAuthHeaders(req)
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http response status code: %d", resp.StatusCode)
+		return nil, newAPIError(resp)
 	}
 
 	var listStreamResponse ListStreamResponse
@@ -296,5 +480,6 @@ func (c *OpenObserveClient) ListStreams(listStreamReqParam *ListStreamRequestPar
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("openobserve.streams", len(listStreamResponse.List)))
 	return &listStreamResponse, nil
 }
@@ -0,0 +1,110 @@
+package openobserve
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// searchCall is a search shared by the callers of identical concurrent searches
+type searchCall struct {
+	done           chan struct{} // closed once the search completed
+	searchResponse *SearchResponse
+	err            error
+	waiters        int                // callers waiting for the search
+	cancel         context.CancelFunc // cancels the search once every caller left
+	span           trace.Span         // span of the shared search, linked to every caller
+	priority       *sharedPriority    // highest priority among the callers
+	queueWait      *QueueWait         // time the shared search waited for the limiter
+}
+
+// searchCoalescer deduplicates identical in-flight searches, like singleflight, but runs the shared
+// search until the last of its callers leaves instead of tying it to the context of the first one
+type searchCoalescer struct {
+	mu    sync.Mutex
+	calls map[string]*searchCall // by searchKey
+}
+
+func newSearchCoalescer() *searchCoalescer {
+	return &searchCoalescer{calls: make(map[string]*searchCall)}
+}
+
+// searchKey identifies a fully resolved search request, including the identity it runs as, which
+// covers every forwarded credential, see Identity. The timeout is left out, it depends on the
+// deadline of each caller rather than on the search.
+func (c *OpenObserveClient) searchKey(searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (string, error) {
+	return sonic.MarshalString(struct {
+		Identity string              `json:"identity"`
+		Param    *SearchRequestParam `json:"param"`
+		Query    Query               `json:"query"`
+	}{c.Identity(), searchReqParam, searchReqBody.Query})
+}
+
+// do runs search, or joins the identical search already running under key. Each caller gets its
+// own copy of the response, whose hits are shared and must not be modified. The search runs with
+// the context values of the first caller, in a span of its own linked to the span of every caller.
+// Its requests are admitted by the limiter with the highest priority among the callers, and the
+// time they wait for it is added to the queue wait of every caller.
+func (s *searchCoalescer) do(ctx context.Context, key string, search func(ctx context.Context) (*SearchResponse, error)) (*SearchResponse, error) {
+	s.mu.Lock()
+	call, ok := s.calls[key]
+	if !ok {
+		// the search outlives a cancelled caller as long as other callers wait for it
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		callCtx, span := tracing.DefaultTracer().Start(callCtx, "OpenObserveClient.sharedSearch")
+		callCtx, priority := withSharedPriority(callCtx, requestPriority(ctx))
+		callCtx, queueWait := WithQueueWait(callCtx)
+		call = &searchCall{done: make(chan struct{}), cancel: cancel, span: span, priority: priority, queueWait: queueWait}
+		s.calls[key] = call
+		go func() {
+			defer cancel()
+			call.searchResponse, call.err = search(callCtx)
+			if call.err != nil {
+				tracing.Error(span, call.err)
+			}
+			span.End()
+			s.mu.Lock()
+			if s.calls[key] == call {
+				delete(s.calls, key)
+			}
+			s.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	s.mu.Unlock()
+	if ok {
+		call.span.AddLink(trace.LinkFromContext(ctx))
+		call.priority.raise(requestPriority(ctx))
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("openobserve.coalesced", ok))
+	defer func() { queueWaitFromContext(ctx).add(call.queueWait.Duration()) }()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		searchResponse := *call.searchResponse
+		// appending to the hits of one caller must not write into the backing array of another
+		searchResponse.Hits = slices.Clip(searchResponse.Hits)
+		return &searchResponse, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// nobody waits for the search anymore, the next identical search starts over
+			call.cancel()
+			if s.calls[key] == call {
+				delete(s.calls, key)
+			}
+		}
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+package openobserve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/bytedance/sonic"
+)
+
+// GetConfig fetches the OpenObserve server configuration, which carries its version
+func (c *OpenObserveClient) GetConfig(ctx context.Context) (*ServerConfig, error) {
+	var serverConfig ServerConfig
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/config", c.BaseUrl), &serverConfig); err != nil {
+		return nil, err
+	}
+	return &serverConfig, nil
+}
+
+// ListOrganizations lists the organizations the authenticated user belongs to
+func (c *OpenObserveClient) ListOrganizations(ctx context.Context) (*ListOrganizationsResponse, error) {
+	var listOrganizationsResponse ListOrganizationsResponse
+	if err := c.getJSON(ctx, fmt.Sprintf("%s/api/organizations", c.BaseUrl), &listOrganizationsResponse); err != nil {
+		return nil, err
+	}
+	return &listOrganizationsResponse, nil
+}
+
+// SearchStreamAvailable reports whether the OpenObserve server supports the _search_stream (SSE) API.
+// The endpoint is probed with an empty search, which is rejected as a bad request by servers
+// supporting it and unknown to older servers. Any other response leaves the support unknown and is
+// returned as an error. The probe bypasses the circuit breaker and the retries, so that a health
+// check cannot suspend the queries of the datasource.
+func (c *OpenObserveClient) SearchStreamAvailable(ctx context.Context, organization string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+
+	searchUrl := fmt.Sprintf("%s/api/%s/_search_stream", c.BaseUrl, organization)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, searchUrl, bytes.NewBufferString("{}"))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setAuthHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300, resp.StatusCode == http.StatusBadRequest:
+		return true, nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusMethodNotAllowed:
+		return false, nil
+	}
+	return false, newAPIError(resp)
+}
+
+// getJSON sends an authenticated GET request and decodes the JSON response into v
+func (c *OpenObserveClient) getJSON(ctx context.Context, url string, v any) error {
+	ctx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+
+	resp, err := c.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.setAuthHeaders(req)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+
+	return sonic.ConfigDefault.NewDecoder(resp.Body).Decode(v)
+}
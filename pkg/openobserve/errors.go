@@ -0,0 +1,60 @@
+package openobserve
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// maxErrorBodySize bounds how much of an error response body is read
+const maxErrorBodySize = 64 * 1024
+
+// APIError is an error returned by the OpenObserve API
+type APIError struct {
+	StatusCode  int    `json:"-"`            // HTTP status code of the response
+	Code        int    `json:"code"`         // OpenObserve error code
+	Message     string `json:"message"`      // human readable error message
+	ErrorDetail string `json:"error_detail"` // optional details, e.g. the SQL error
+	TraceID     string `json:"trace_id"`     // trace id of the failed search, if any
+	Body        string `json:"-"`            // raw response body when it is not an OpenObserve JSON error
+}
+
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "openobserve error (status %d", e.StatusCode)
+	if e.Code != 0 && e.Code != e.StatusCode {
+		fmt.Fprintf(&sb, ", code %d", e.Code)
+	}
+	sb.WriteString(")")
+
+	switch {
+	case e.Message != "":
+		fmt.Fprintf(&sb, ": %s", e.Message)
+	case e.Body != "":
+		fmt.Fprintf(&sb, ": %s", e.Body)
+	}
+	if e.ErrorDetail != "" && e.ErrorDetail != e.Message {
+		fmt.Fprintf(&sb, ": %s", e.ErrorDetail)
+	}
+	if e.TraceID != "" {
+		fmt.Fprintf(&sb, " (trace_id: %s)", e.TraceID)
+	}
+	return sb.String()
+}
+
+// newAPIError builds an APIError from a non successful response, parsing OpenObserve's JSON error body if present
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err := sonic.Unmarshal(body, apiErr); err != nil || (apiErr.Message == "" && apiErr.ErrorDetail == "") {
+		apiErr.Body = strings.TrimSpace(string(body))
+	}
+	if apiErr.Body == "" && apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,56 @@
+package openobserve
+
+import (
+	"time"
+)
+
+// histogramTimeLayout is the format of the time buckets returned by histogram(...)
+const histogramTimeLayout = "2006-01-02T15:04:05"
+
+// AlignToTimeBucket returns the start of the time bucket of the SQL containing timestamp (microseconds)
+func (s *SQL) AlignToTimeBucket(timestamp int64) int64 {
+	interval := s.TimeBucketInterval.Microseconds()
+	if interval <= 0 {
+		return timestamp
+	}
+	return timestamp - timestamp%interval
+}
+
+// MergeTimeBuckets merges the result of a time-bucketed search over the slice of the time range
+// starting at sliceStart (microseconds, aligned to a time bucket) into the result of the same search
+// over an earlier time range. The buckets of the slice replace the previous ones, and the buckets
+// ending before windowStart, the start of the new time range, are dropped. Rows are sorted by the
+// ORDER BY of the SQL, previous rows first otherwise.
+func MergeTimeBuckets(parsedSql *SQL, previous *SearchResponse, slice *SearchResponse, windowStart int64, sliceStart int64) *SearchResponse {
+	column, interval := parsedSql.TimeBucketColumn, parsedSql.TimeBucketInterval.Microseconds()
+
+	merged := *slice
+	merged.Hits = make([]map[string]any, 0, len(previous.Hits)+len(slice.Hits))
+	for _, hit := range previous.Hits {
+		if bucket, ok := bucketTime(hit, column); ok && bucket+interval > windowStart && bucket < sliceStart {
+			merged.Hits = append(merged.Hits, hit)
+		}
+	}
+	merged.Hits = append(merged.Hits, slice.Hits...)
+	if len(parsedSql.OrderBy) > 0 {
+		sortHits(merged.Hits, parsedSql.OrderBy)
+	}
+	merged.Total = len(merged.Hits)
+	merged.From, merged.Size = 0, len(merged.Hits)
+	return &merged
+}
+
+// bucketTime returns the start of the time bucket of a hit in microseconds
+func bucketTime(hit map[string]any, column string) (int64, bool) {
+	switch value := hit[column].(type) {
+	case float64:
+		return int64(value), true
+	case string:
+		timestamp, err := time.Parse(histogramTimeLayout, value)
+		if err != nil {
+			return 0, false
+		}
+		return timestamp.UnixMicro(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,287 @@
+package openobserve
+
+import (
+	"container/heap"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// request priorities, higher first
+const (
+	priorityDefault  = iota // dashboards, Explore and resource requests
+	priorityAlerting        // alert rule evaluations, which must not miss their deadline because of dashboards
+)
+
+// requestLimiter bounds the requests in flight to OpenObserve, overall and per query type. A request
+// holds its slot until its response body is closed, streamed searches for as long as they stream.
+// Waiting requests are admitted by priority, then in arrival order, skipping the ones whose query
+// type is at its limit. Search cancellations bypass the limiter so that they are never queued
+// behind the searches they cancel.
+type requestLimiter struct {
+	maxRunning     int            // unlimited if not positive
+	maxTypeRunning map[string]int // by query type, unlimited if missing
+
+	mu          sync.Mutex
+	running     int
+	typeRunning map[string]int
+	queue       requestQueue
+	seq         uint64 // arrival order of the waiting requests
+}
+
+// requestWaiter is a request waiting for the limiter to admit it
+type requestWaiter struct {
+	queryType string
+	priority  int
+	seq       uint64
+	index     int           // in the queue, maintained by heap
+	admitted  chan struct{} // closed once the request may be sent
+}
+
+func newRequestLimiter(maxRunning int, maxTypeRunning map[string]int) *requestLimiter {
+	return &requestLimiter{
+		maxRunning:     maxRunning,
+		maxTypeRunning: maxTypeRunning,
+		typeRunning:    make(map[string]int),
+	}
+}
+
+// limit returns the number of requests of queryType which may be in flight concurrently, 0 if unlimited
+func (l *requestLimiter) limit(queryType string) int {
+	typeMax, ok := l.maxTypeRunning[queryType]
+	switch {
+	case !ok:
+		return max(l.maxRunning, 0)
+	case l.maxRunning <= 0:
+		return typeMax
+	}
+	return min(typeMax, l.maxRunning)
+}
+
+// acquire waits until a request may be sent for ctx and returns the function releasing its slot.
+// The request is limited as its query type, see WithQueryType, and admitted first if it belongs to
+// an alerting query, see WithAlerting, or to a shared search one joined. The time waited is added
+// to the queue wait of ctx.
+func (l *requestLimiter) acquire(ctx context.Context) (func(), error) {
+	queryType := QueryTypeFromContext(ctx)
+	start := time.Now()
+	defer func() { queueWaitFromContext(ctx).add(time.Since(start)) }()
+	waiter := &requestWaiter{queryType: queryType, priority: requestPriority(ctx), admitted: make(chan struct{})}
+
+	// the priority of a shared search may be raised while its request waits
+	shared := sharedPriorityFromContext(ctx)
+	if shared != nil {
+		shared.mu.Lock()
+		waiter.priority = max(waiter.priority, shared.priority)
+	}
+	l.mu.Lock()
+	waiter.seq = l.seq
+	l.seq++
+	heap.Push(&l.queue, waiter)
+	l.dispatch()
+	l.mu.Unlock()
+	if shared != nil {
+		shared.queued[waiter] = l
+		shared.mu.Unlock()
+		defer shared.dequeue(waiter)
+	}
+
+	var once sync.Once
+	release := func() { once.Do(func() { l.release(queryType) }) }
+	select {
+	case <-waiter.admitted:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-waiter.admitted:
+		// admitted while giving up, hand the slot over to the next request
+		l.releaseLocked(queryType)
+	default:
+		heap.Remove(&l.queue, waiter.index)
+	}
+	return nil, ctx.Err()
+}
+
+// release frees the slot of a request of queryType
+func (l *requestLimiter) release(queryType string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked(queryType)
+}
+
+func (l *requestLimiter) releaseLocked(queryType string) {
+	l.running--
+	l.typeRunning[queryType]--
+	l.dispatch()
+}
+
+// reprioritize moves waiter up the queue to priority if it still waits
+func (l *requestLimiter) reprioritize(waiter *requestWaiter, priority int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if waiter.index < 0 || priority <= waiter.priority {
+		return
+	}
+	waiter.priority = priority
+	heap.Fix(&l.queue, waiter.index)
+}
+
+// dispatch admits the waiting requests while slots are free, l.mu must be held
+func (l *requestLimiter) dispatch() {
+	var blocked []*requestWaiter // waiting for a slot of their query type
+	for (l.maxRunning <= 0 || l.running < l.maxRunning) && l.queue.Len() > 0 {
+		waiter := heap.Pop(&l.queue).(*requestWaiter)
+		if typeMax, ok := l.maxTypeRunning[waiter.queryType]; ok && l.typeRunning[waiter.queryType] >= typeMax {
+			blocked = append(blocked, waiter)
+			continue
+		}
+		l.running++
+		l.typeRunning[waiter.queryType]++
+		close(waiter.admitted)
+	}
+	for _, waiter := range blocked {
+		heap.Push(&l.queue, waiter)
+	}
+}
+
+// requestQueue is a priority queue of waiting requests, see container/heap
+type requestQueue []*requestWaiter
+
+func (q requestQueue) Len() int { return len(q) }
+
+func (q requestQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q requestQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *requestQueue) Push(x any) {
+	waiter := x.(*requestWaiter)
+	waiter.index = len(*q)
+	*q = append(*q, waiter)
+}
+
+func (q *requestQueue) Pop() any {
+	old := *q
+	waiter := old[len(old)-1]
+	waiter.index = -1 // no longer queued
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return waiter
+}
+
+// releasingBody releases the limiter slot of a request once its response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+type alertingKey struct{}
+
+// WithAlerting marks ctx as belonging to a query sent by Grafana alerting, whose requests are
+// admitted by the limiter before the others
+func WithAlerting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, alertingKey{}, true)
+}
+
+// IsAlerting reports whether ctx belongs to a query sent by Grafana alerting, see WithAlerting
+func IsAlerting(ctx context.Context) bool {
+	alerting, _ := ctx.Value(alertingKey{}).(bool)
+	return alerting
+}
+
+// requestPriority returns the priority of the requests of ctx
+func requestPriority(ctx context.Context) int {
+	if IsAlerting(ctx) {
+		return priorityAlerting
+	}
+	return priorityDefault
+}
+
+type sharedPriorityKey struct{}
+
+// sharedPriority is the priority of the requests of a search shared by several callers, the
+// highest among them. Raising it moves the requests already waiting for the limiter up the queue.
+type sharedPriority struct {
+	mu       sync.Mutex
+	priority int
+	queued   map[*requestWaiter]*requestLimiter // requests waiting for their limiter
+}
+
+// withSharedPriority returns a copy of ctx whose requests are admitted with the returned priority
+func withSharedPriority(ctx context.Context, priority int) (context.Context, *sharedPriority) {
+	shared := &sharedPriority{priority: priority, queued: make(map[*requestWaiter]*requestLimiter)}
+	return context.WithValue(ctx, sharedPriorityKey{}, shared), shared
+}
+
+func sharedPriorityFromContext(ctx context.Context) *sharedPriority {
+	shared, _ := ctx.Value(sharedPriorityKey{}).(*sharedPriority)
+	return shared
+}
+
+// raise raises the priority to at least priority
+func (p *sharedPriority) raise(priority int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if priority <= p.priority {
+		return
+	}
+	p.priority = priority
+	for waiter, limiter := range p.queued {
+		limiter.reprioritize(waiter, priority)
+	}
+}
+
+func (p *sharedPriority) dequeue(waiter *requestWaiter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.queued, waiter)
+}
+
+type queueWaitKey struct{}
+
+// QueueWait sums up the time the requests of a query waited for the limiter
+type QueueWait struct {
+	nanos atomic.Int64
+}
+
+// WithQueueWait returns a copy of ctx whose requests add the time they wait for the limiter to the
+// returned QueueWait
+func WithQueueWait(ctx context.Context) (context.Context, *QueueWait) {
+	wait := &QueueWait{}
+	return context.WithValue(ctx, queueWaitKey{}, wait), wait
+}
+
+// queueWaitFromContext returns the queue wait of ctx, which is discarded if there is none
+func queueWaitFromContext(ctx context.Context) *QueueWait {
+	if wait, ok := ctx.Value(queueWaitKey{}).(*QueueWait); ok {
+		return wait
+	}
+	return &QueueWait{}
+}
+
+func (w *QueueWait) add(d time.Duration) {
+	w.nanos.Add(int64(d))
+}
+
+// Duration returns the time waited so far
+func (w *QueueWait) Duration() time.Duration {
+	return time.Duration(w.nanos.Load())
+}
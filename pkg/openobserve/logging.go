@@ -0,0 +1,78 @@
+package openobserve
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// redacted replaces the values of credentials in logs
+const redacted = "[REDACTED]"
+
+// secureHeaders are the headers carrying credentials, headers whose name contains one of
+// secureHeaderWords are redacted as well
+var (
+	secureHeaders = []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Set-Cookie",
+		backend.OAuthIdentityIDTokenHeaderName,
+	}
+	secureHeaderWords = []string{"token", "secret", "password", "api-key", "apikey"}
+)
+
+// Logger returns the logger of ctx, which adds the contextual attributes set by the SDK and by
+// WithLogAttributes to every line
+func Logger(ctx context.Context) log.Logger {
+	return log.DefaultLogger.FromContext(ctx)
+}
+
+// WithLogAttributes returns a copy of ctx whose logger adds the given key/value pairs to every line.
+// The attributes of ctx are copied rather than appended to, they may be shared by concurrent queries,
+// partitions and pages, which log.WithContextualAttributes would write into the same array.
+func WithLogAttributes(ctx context.Context, args ...any) context.Context {
+	attributes := slices.Concat(log.ContextualAttributesFromContext(ctx), args)
+	return &logContext{Context: ctx, attributes: log.WithContextualAttributes(context.Background(), attributes)}
+}
+
+// logContext overrides the log attributes of a context
+type logContext struct {
+	context.Context
+	attributes context.Context // holds the log attributes only
+}
+
+func (c *logContext) Value(key any) any {
+	if value := c.attributes.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
+
+// RedactHeaders returns a copy of headers in which the values of credentials are redacted
+func RedactHeaders(headers http.Header) http.Header {
+	redactedHeaders := headers.Clone()
+	for name := range redactedHeaders {
+		if isSecureHeader(name) {
+			redactedHeaders[name] = []string{redacted}
+		}
+	}
+	return redactedHeaders
+}
+
+func isSecureHeader(name string) bool {
+	if slices.ContainsFunc(secureHeaders, func(header string) bool { return strings.EqualFold(header, name) }) {
+		return true
+	}
+	lowered := strings.ToLower(name)
+	return slices.ContainsFunc(secureHeaderWords, func(word string) bool { return strings.Contains(lowered, word) })
+}
+
+// requestLogAttributes returns the key/value pairs describing req in logs, without its credentials
+func requestLogAttributes(req *http.Request) []any {
+	return []any{"method", req.Method, "url", req.URL.Redacted(), "headers", RedactHeaders(req.Header)}
+}
@@ -0,0 +1,95 @@
+package openobserve
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// MetricsNamespace prefixes the Prometheus metrics of the plugin
+const MetricsNamespace = "openobserve_datasource"
+
+// requests to OpenObserve, by endpoint and query type
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: MetricsNamespace,
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the requests to OpenObserve until the response headers, by endpoint.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"endpoint", "query_type"})
+	responseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "response_bytes_total",
+		Help:      "Bytes of the response bodies received from OpenObserve, by endpoint.",
+	}, []string{"endpoint", "query_type"})
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "request_errors_total",
+		Help:      "Failed requests to OpenObserve, by endpoint and HTTP status, or network, timeout or cancelled.",
+	}, []string{"endpoint", "query_type", "status"})
+	requestRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "request_retries_total",
+		Help:      "Requests to OpenObserve retried after a transient error, by endpoint.",
+	}, []string{"endpoint", "query_type"})
+	circuitBreakerRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "circuit_breaker_rejections_total",
+		Help:      "Requests to OpenObserve failed fast because the circuit breaker is open.",
+	}, []string{"query_type"})
+	hitsDecoded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: MetricsNamespace,
+		Name:      "hits_decoded_total",
+		Help:      "Hits decoded from the search responses of OpenObserve.",
+	}, []string{"query_type"})
+)
+
+// noQueryType labels the requests which are not sent for a query, e.g. health checks
+const noQueryType = "none"
+
+type queryTypeKey struct{}
+
+// WithQueryType tags ctx with the Grafana query type the requests sent with ctx are labelled with
+func WithQueryType(ctx context.Context, queryType string) context.Context {
+	return context.WithValue(ctx, queryTypeKey{}, queryType)
+}
+
+// QueryTypeFromContext returns the query type ctx was tagged with by WithQueryType
+func QueryTypeFromContext(ctx context.Context) string {
+	if queryType, ok := ctx.Value(queryTypeKey{}).(string); ok && queryType != "" {
+		return queryType
+	}
+	return noQueryType
+}
+
+// endpointLabel returns the OpenObserve API a request path belongs to, e.g. _search for
+// /api/{organization}/_search
+func endpointLabel(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(segments) == 3 && segments[0] == "api":
+		return segments[2] // _search, _search_stream, _search_partition or streams
+	case len(segments) >= 3 && segments[0] == "api" && segments[2] == "streams":
+		return "streams"
+	case len(segments) == 2 && segments[0] == "api":
+		return segments[1] // organizations or clusters
+	case len(segments) == 1:
+		return segments[0] // config
+	}
+	return "other"
+}
+
+// countingBody counts the bytes read from a response body
+type countingBody struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.counter.Add(float64(n))
+	return n, err
+}
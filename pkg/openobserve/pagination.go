@@ -0,0 +1,52 @@
+package openobserve
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultPageSize is the default number of hits requested per page by a paginated search
+const DefaultPageSize = 10000
+
+// PaginatedSearch performs a search returning up to searchReqBody.Size hits starting at
+// searchReqBody.From, in pages of at most pageSize hits. Pages are requested until enough hits are
+// collected, a page comes back short or the total number of hits reported by OpenObserve is reached.
+// Searches which fit in a single page are sent as is, searchReqBody.TrackTotalHits included, the
+// first page of other searches always tracks the total hits and the next pages never do.
+func (c *OpenObserveClient) PaginatedSearch(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody, pageSize int64) (*SearchResponse, error) {
+	if pageSize <= 0 || searchReqBody.Size <= pageSize {
+		return c.Search(ctx, searchReqParam, searchReqBody)
+	}
+
+	merged := &SearchResponse{Hits: make([]map[string]any, 0, pageSize)}
+	from, wanted := searchReqBody.From, searchReqBody.Size
+	for page := 1; ; page++ {
+		pageReqBody := *searchReqBody
+		pageReqBody.From = from
+		pageReqBody.Size = min(pageSize, wanted-int64(len(merged.Hits)))
+		// OpenObserve only counts all the matching hits on request, which tells when to stop
+		pageReqBody.TrackTotalHits = page == 1
+		searchResponse, err := c.Search(ctx, searchReqParam, &pageReqBody)
+		if err != nil {
+			return nil, fmt.Errorf("search page %d: %w", page, err)
+		}
+
+		total := merged.Total
+		if page == 1 {
+			total = searchResponse.Total
+		}
+		merged.Hits = append(merged.Hits, searchResponse.Hits...)
+		pageHits := int64(len(searchResponse.Hits))
+		searchResponse.Hits = nil
+		merged.mergeMetadata(searchResponse)
+		merged.Total = total // every page reports the same total
+		from += pageHits
+
+		if pageHits < pageReqBody.Size || int64(len(merged.Hits)) >= wanted || (total > 0 && from >= int64(total)) {
+			Logger(ctx).Debug("Paginated search completed", "pages", page, "hits", len(merged.Hits), "total", total)
+			break
+		}
+	}
+	merged.From, merged.Size = int(searchReqBody.From), len(merged.Hits)
+	return merged, nil
+}
@@ -0,0 +1,288 @@
+package openobserve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bytedance/sonic"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultPartitionConcurrency is the default number of partitions of a search run concurrently
+const DefaultPartitionConcurrency = 4
+
+// PartitionOptions configures a partitioned search
+type PartitionOptions struct {
+	Concurrency int             // number of partitions searched concurrently
+	OrderBy     []OrderByColumn // ORDER BY clause of the SQL, the merged hits are sorted accordingly
+	PageSize    int64           // partitions returning more hits are paginated, see PaginatedSearch
+	// Aggregates is the aggregate function of the columns of a GROUP BY SQL which are not grouped,
+	// nil if the hits are not groups. A group spanning several partitions, e.g. a time bucket across
+	// a partition boundary, is returned by each of them and merged, see mergeGroups.
+	Aggregates map[string]string
+}
+
+// SearchPartitions asks OpenObserve how to split the time range of a search into partitions
+func (c *OpenObserveClient) SearchPartitions(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (*SearchPartitionResponse, error) {
+	ctx, cancel := c.withTimeout(ctx, 0)
+	defer cancel()
+
+	partitionReqBodyBytes, err := sonic.Marshal(&SearchPartitionRequestBody{
+		Sql:       searchReqBody.Sql,
+		StartTime: searchReqBody.StartTime,
+		EndTime:   searchReqBody.EndTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	partitionUrl := fmt.Sprintf("%s/api/%s/_search_partition", c.BaseUrl, searchReqParam.Organization)
+	resp, err := c.doWithRetry(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, partitionUrl, bytes.NewBuffer(partitionReqBodyBytes))
+		if err != nil {
+			return nil, err
+		}
+		q := req.URL.Query()
+		q.Set("type", searchReqParam.StreamType)
+		req.URL.RawQuery = q.Encode()
+
+		req.Header.Set("Content-Type", "application/json")
+		c.setAuthHeaders(req)
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+
+	var partitionResponse SearchPartitionResponse
+	if err := sonic.ConfigDefault.NewDecoder(resp.Body).Decode(&partitionResponse); err != nil {
+		return nil, err
+	}
+	return &partitionResponse, nil
+}
+
+// PartitionedSearch splits the time range of a search into the partitions recommended by OpenObserve
+// and searches them concurrently. The hits are merged in the order of the partitions, or sorted
+// according to opts.OrderBy, and cut to the requested from and size. The statistics of the
+// partitions add up. Once the first partitions hold enough hits, the remaining ones are skipped
+// unless the hits have to be sorted or merged.
+func (c *OpenObserveClient) PartitionedSearch(ctx context.Context, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody, opts PartitionOptions) (*SearchResponse, error) {
+	partitionResponse, err := c.SearchPartitions(ctx, searchReqParam, searchReqBody)
+	if err != nil {
+		return nil, fmt.Errorf("search partitions: %w", err)
+	}
+	partitions := partitionResponse.Partitions
+	if len(partitions) <= 1 {
+		return c.PaginatedSearch(ctx, searchReqParam, searchReqBody, opts.PageSize)
+	}
+	Logger(ctx).Debug("Partitioned search", "partitions", len(partitions), "traceID", partitionResponse.TraceID)
+
+	// every partition may contribute all the requested hits, the offset is applied once merged
+	wanted := int(searchReqBody.From + searchReqBody.Size)
+	results := make([]*SearchResponse, len(partitions))
+	var mu sync.Mutex
+	enough := func() bool {
+		if len(opts.OrderBy) > 0 || opts.Aggregates != nil || searchReqBody.Size <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		hits := 0
+		for _, result := range results {
+			if result == nil {
+				return false
+			}
+			if hits += len(result.Hits); hits >= wanted {
+				return true
+			}
+		}
+		return false
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(opts.Concurrency, 1))
+	for i, partition := range partitions {
+		g.Go(func() error {
+			if enough() {
+				return nil
+			}
+			partitionReqBody := *searchReqBody
+			partitionReqBody.StartTime, partitionReqBody.EndTime = partition[0], partition[1]
+			partitionReqBody.From = 0
+			partitionReqBody.Size = int64(wanted)
+			searchResponse, err := c.PaginatedSearch(gctx, searchReqParam, &partitionReqBody, opts.PageSize)
+			if err != nil {
+				return fmt.Errorf("search partition %d/%d: %w", i+1, len(partitions), err)
+			}
+			mu.Lock()
+			results[i] = searchResponse
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	merged := &SearchResponse{}
+	for _, result := range results {
+		if result == nil {
+			continue // skipped, the previous partitions hold enough hits
+		}
+		merged.Hits = append(merged.Hits, result.Hits...)
+		result.Hits = nil
+		merged.mergeMetadata(result)
+	}
+	if opts.Aggregates != nil {
+		// OpenObserve counts the groups, those spanning several partitions were counted by each
+		merged.Hits = mergeGroups(merged.Hits, opts.Aggregates)
+		merged.Total = len(merged.Hits)
+	}
+	if len(opts.OrderBy) > 0 {
+		sortHits(merged.Hits, opts.OrderBy)
+	}
+	from := min(int(searchReqBody.From), len(merged.Hits))
+	merged.Hits = merged.Hits[from:]
+	if searchReqBody.Size > 0 && len(merged.Hits) > int(searchReqBody.Size) {
+		merged.Hits = merged.Hits[:searchReqBody.Size]
+	}
+	merged.From, merged.Size = int(searchReqBody.From), len(merged.Hits)
+	if partitionResponse.TraceID != "" {
+		merged.TraceID = partitionResponse.TraceID
+	}
+	return merged, nil
+}
+
+// mergeGroups merges the hits of the same group returned by different partitions, i.e. the hits with
+// the same values in the columns which are not aggregated. The aggregated columns of a group are
+// combined with their aggregate function, the group keeps the position of its first hit. Hits are
+// copied before being modified, they may be shared with coalesced searches.
+func mergeGroups(hits []map[string]any, aggregates map[string]string) []map[string]any {
+	merged := make([]map[string]any, 0, len(hits))
+	groups := make(map[string]int, len(hits)) // group key --> index in merged
+	copied := make(map[int]bool)
+	for _, hit := range hits {
+		key := groupKey(hit, aggregates)
+		i, ok := groups[key]
+		if !ok {
+			groups[key] = len(merged)
+			merged = append(merged, hit)
+			continue
+		}
+		if !copied[i] {
+			merged[i] = maps.Clone(merged[i])
+			copied[i] = true
+		}
+		for column, function := range aggregates {
+			merged[i][column] = combineAggregates(function, merged[i][column], hit[column])
+		}
+	}
+	return merged
+}
+
+// groupKey identifies the group of a hit by the values of its columns which are not aggregated
+func groupKey(hit map[string]any, aggregates map[string]string) string {
+	group := make(map[string]any, len(hit))
+	for column, value := range hit {
+		if _, ok := aggregates[column]; !ok {
+			group[column] = value
+		}
+	}
+	key, _ := sonic.ConfigStd.MarshalToString(group) // sorts the columns
+	return key
+}
+
+// combineAggregates combines the values of an aggregate function over two time ranges into its
+// value over both of them
+func combineAggregates(function string, a, b any) any {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	switch function {
+	case "count", "sum":
+		if a, ok := a.(float64); ok {
+			if b, ok := b.(float64); ok {
+				return a + b
+			}
+		}
+	case "min":
+		if compareValues(b, a) < 0 {
+			return b
+		}
+	case "max":
+		if compareValues(b, a) > 0 {
+			return b
+		}
+	}
+	return a
+}
+
+// sortHits sorts hits according to an ORDER BY clause, keeping the order of equal hits
+func sortHits(hits []map[string]any, orderBy []OrderByColumn) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		for _, order := range orderBy {
+			c := compareValues(hits[i][order.Column], hits[j][order.Column])
+			if c == 0 {
+				continue
+			}
+			if order.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// compareValues compares two JSON values, missing values sort first
+func compareValues(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case b:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,79 @@
+package openobserve
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// cancelSearchTimeout bounds the cancel call issued for an abandoned search
+const cancelSearchTimeout = 10 * time.Second
+
+// newTraceID generates a W3C trace id. OpenObserve derives the trace id of a search from the
+// traceparent header, which lets the plugin know the id of a running query before it responds.
+func newTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// setTraceparent tags req with the given trace id using a W3C traceparent header. When ctx carries
+// a span, the span is the parent of the search and its sampling decision is propagated, the trace id
+// stays the one of the search so that OpenObserve can cancel it alone.
+func setTraceparent(ctx context.Context, req *http.Request, traceID string) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	parentID, flags := spanContext.SpanID().String(), spanContext.TraceFlags()
+	if !spanContext.IsValid() {
+		spanID := make([]byte, 8)
+		rand.Read(spanID)
+		parentID, flags = hex.EncodeToString(spanID), trace.FlagsSampled
+	}
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-%s", traceID, parentID, flags))
+}
+
+// cancelOnDone cancels the search identified by traceID on the OpenObserve side once ctx is
+// cancelled or times out. The returned stop function must be called when the search completes.
+func (c *OpenObserveClient) cancelOnDone(ctx context.Context, organization, traceID string) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		// ctx is already done, keep its values (forwarded headers, tracing) but not its cancellation
+		cancelCtx := context.WithoutCancel(ctx)
+
+		Logger(ctx).Debug("Cancelling abandoned search", "organization", organization, "traceID", traceID, "reason", ctx.Err())
+		if err := c.CancelSearch(cancelCtx, organization, traceID); err != nil {
+			Logger(ctx).Warn("Failed to cancel abandoned search", "organization", organization, "traceID", traceID, "error", err)
+		}
+	})
+}
+
+// CancelSearch cancels a running search through the OpenObserve query manager API. The call is
+// bounded by cancelSearchTimeout, or by an earlier deadline of ctx.
+func (c *OpenObserveClient) CancelSearch(ctx context.Context, organization, traceID string) error {
+	ctx, cancel := c.withTimeout(ctx, cancelSearchTimeout)
+	defer cancel()
+
+	cancelUrl := fmt.Sprintf("%s/api/%s/query_manager/%s", c.BaseUrl, organization, traceID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, cancelUrl, nil)
+	if err != nil {
+		return err
+	}
+	c.setAuthHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the search may have finished in the meantime, which is not an error
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("cancel search failed: %w", newAPIError(resp))
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package openobserve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how idempotent requests to OpenObserve are retried on transient errors
+type RetryPolicy struct {
+	MaxAttempts    int           // total number of attempts including the first one, 1 disables retries
+	InitialBackoff time.Duration // backoff before the first retry, doubled on every further retry
+	MaxBackoff     time.Duration // upper bound of the backoff and of an honored Retry-After
+}
+
+// DefaultRetryPolicy is used when the datasource does not configure retries
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// isRetryableStatus reports whether the response status code denotes a transient OpenObserve error
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryableError reports whether a transport error is transient, e.g. a timeout or a connection
+// reset by a restarting OpenObserve node. Configuration errors such as TLS failures are not retried.
+func isRetryableError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns the delay before the given retry (1 for the first retry) with full jitter
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff << (retry - 1)
+	if backoff <= 0 || backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff)) + 1)
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// doWithRetry sends the request built by newRequest, retrying network errors and transient
+// status codes according to the retry policy. Retries never outlive the deadline of ctx.
+// newRequest is called for every attempt so that the request body can be sent again.
+// Requests fail fast with ErrCircuitOpen while the circuit breaker is open, every attempt waits
+// for a slot of the limiter which is held until the response body is closed.
+func (c *OpenObserveClient) doWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	done, err := c.breaker.allow()
+	if err != nil {
+		circuitBreakerRejections.WithLabelValues(QueryTypeFromContext(ctx)).Inc()
+		return nil, err
+	}
+	resp, err := c.sendWithRetry(ctx, newRequest)
+	done(classifyOutcome(ctx, resp, err))
+	return resp, err
+}
+
+// sendWithRetry implements doWithRetry, regardless of the circuit breaker
+func (c *OpenObserveClient) sendWithRetry(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		release, err := c.limiter.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			release()
+		} else {
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+		}
+		observeRequest(ctx, req, start, resp, err)
+		if ctx.Err() != nil || attempt >= c.retryPolicy.MaxAttempts {
+			return resp, err
+		}
+		if err != nil && !isRetryableError(err) {
+			return nil, err
+		}
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay := c.retryPolicy.backoff(attempt)
+		if err == nil {
+			if after, ok := retryAfter(resp); ok {
+				delay = min(after, c.retryPolicy.MaxBackoff)
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			Logger(ctx).Debug("Not retrying OpenObserve request, the query deadline would be exceeded", "url", req.URL.Path, "attempt", attempt, "delay", delay)
+			return resp, err
+		}
+
+		requestRetries.WithLabelValues(endpointLabel(req.URL.Path), QueryTypeFromContext(ctx)).Inc()
+		if err != nil {
+			Logger(ctx).Debug("Retrying OpenObserve request", "url", req.URL.Path, "attempt", attempt, "delay", delay, "error", err)
+		} else {
+			Logger(ctx).Debug("Retrying OpenObserve request", "url", req.URL.Path, "attempt", attempt, "delay", delay, "statusCode", resp.StatusCode)
+			io.Copy(io.Discard, resp.Body) // drain the body so the connection can be reused
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// observeRequest records the duration and outcome of a request to OpenObserve, and counts the
+// bytes of its response body as they are read
+func observeRequest(ctx context.Context, req *http.Request, start time.Time, resp *http.Response, err error) {
+	endpoint, queryType := endpointLabel(req.URL.Path), QueryTypeFromContext(ctx)
+	requestDuration.WithLabelValues(endpoint, queryType).Observe(time.Since(start).Seconds())
+	if err != nil {
+		status := "network"
+		var netErr net.Error
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			status = "cancelled"
+		case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+			status = "timeout"
+		}
+		requestErrors.WithLabelValues(endpoint, queryType, status).Inc()
+		return
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		requestErrors.WithLabelValues(endpoint, queryType, strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, counter: responseBytes.WithLabelValues(endpoint, queryType)}
+}
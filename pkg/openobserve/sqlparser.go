@@ -2,7 +2,9 @@ package openobserve
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"regexp"
 
@@ -31,7 +33,31 @@ type SQL struct {
 	selectColumns  []string
 	whereVariables []string
 	CompletedSql   string
-	Limit          int64 // Extracted LIMIT value from SQL, 0 means no limit specified
+	Limit          int64           // Extracted LIMIT value from SQL, 0 means no limit specified
+	OrderBy        []OrderByColumn // Extracted ORDER BY clause, empty for the default order of OpenObserve
+	Aggregated     bool            // Whether the SQL aggregates rows (GROUP BY, DISTINCT or aggregate functions)
+	TimeBucketed   bool            // Whether the SQL groups rows by histogram(...) time buckets
+	// TimeBucketColumn is the alias of the histogram(...) column the rows are grouped by, set only
+	// when the histogram has an explicit interval so that buckets do not depend on the time range
+	TimeBucketColumn   string
+	TimeBucketInterval time.Duration // explicit interval of the histogram(...) column
+	// Aggregates is the aggregate function of every column of a GROUP BY SQL which is not grouped,
+	// set only when the groups of consecutive time ranges can be merged, see extractAggregatesFromSql
+	Aggregates map[string]string
+}
+
+// OrderByColumn is a column of the ORDER BY clause of a SQL
+type OrderByColumn struct {
+	Column string
+	Desc   bool
+}
+
+// Partitionable reports whether the results of the SQL over consecutive time ranges can be merged
+// into the result over the whole range: rows are either not aggregated or aggregated per time bucket
+// of an explicit interval, so that every time range uses the same buckets, with aggregates which
+// can be combined across time ranges
+func (s *SQL) Partitionable() bool {
+	return !s.Aggregated || (s.TimeBucketColumn != "" && s.Aggregates != nil)
 }
 
 const (
@@ -158,6 +184,9 @@ func (sp *SqlParser) ParseSql(sqlStr string) (*SQL, error) {
 
 	// Extract LIMIT value from SQL using sqlparser (more reliable for LIMIT extraction)
 	limitValue := extractLimitFromSql(sqlStr)
+	orderBy := extractOrderByFromSql(sqlStr)
+	aggregated, timeBucketed, timeBucketColumn, timeBucketInterval := extractAggregationFromSql(sqlStr)
+	aggregates := extractAggregatesFromSql(sqlStr)
 
 	if len(selectedColumns) == 1 && selectedColumns[0] == "*" {
 		return &SQL{
@@ -165,6 +194,13 @@ func (sp *SqlParser) ParseSql(sqlStr string) (*SQL, error) {
 			selectColumns:  selectedColumns,
 			whereVariables: whereVariables,
 			Limit:          limitValue,
+			OrderBy:        orderBy,
+			Aggregated:     aggregated,
+			TimeBucketed:   timeBucketed,
+
+			TimeBucketColumn:   timeBucketColumn,
+			TimeBucketInterval: timeBucketInterval,
+			Aggregates:         aggregates,
 		}, nil
 	}
 
@@ -173,6 +209,13 @@ func (sp *SqlParser) ParseSql(sqlStr string) (*SQL, error) {
 		selectColumns:  selectedColumns,
 		whereVariables: whereVariables,
 		Limit:          limitValue,
+		OrderBy:        orderBy,
+		Aggregated:     aggregated,
+		TimeBucketed:   timeBucketed,
+
+		TimeBucketColumn:   timeBucketColumn,
+		TimeBucketInterval: timeBucketInterval,
+		Aggregates:         aggregates,
 	}, nil
 }
 
@@ -277,3 +320,168 @@ func extractLimitFromSql(sqlStr string) int64 {
 
 	return limitValue
 }
+
+// extractOrderByFromSql extracts the ORDER BY clause from SQL string using sqlparser
+func extractOrderByFromSql(sqlStr string) []OrderByColumn {
+	stmt, err := sqlparser.Parse(sqlStr)
+	if err != nil {
+		return nil
+	}
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil
+	}
+
+	orderBy := make([]OrderByColumn, 0, len(selectStmt.OrderBy))
+	for _, order := range selectStmt.OrderBy {
+		orderBy = append(orderBy, OrderByColumn{
+			Column: strings.ReplaceAll(sqlparser.String(order.Expr), "`", ""),
+			Desc:   order.Direction == sqlparser.DescScr,
+		})
+	}
+	return orderBy
+}
+
+// extractAggregationFromSql reports whether the SQL aggregates rows, whether it groups them by
+// histogram(...) time buckets and the alias and interval of the histogram column when its interval
+// is explicit, using sqlparser
+func extractAggregationFromSql(sqlStr string) (aggregated bool, timeBucketed bool, timeBucketColumn string, timeBucketInterval time.Duration) {
+	stmt, err := sqlparser.Parse(sqlStr)
+	if err != nil {
+		return false, false, "", 0
+	}
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return false, false, "", 0
+	}
+
+	aggregated = len(selectStmt.GroupBy) > 0 || selectStmt.Distinct != ""
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if funcExpr, ok := node.(*sqlparser.FuncExpr); ok && funcExpr.IsAggregate() {
+			aggregated = true
+		}
+		return true, nil
+	}, selectStmt.SelectExprs)
+
+	for _, expr := range selectStmt.GroupBy {
+		if funcExpr, ok := expr.(*sqlparser.FuncExpr); ok && funcExpr.Name.Lowered() == "histogram" {
+			timeBucketed = true
+		}
+	}
+	// GROUP BY may also refer to the alias of a histogram(...) column
+	for _, selectExpr := range selectStmt.SelectExprs {
+		aliased, ok := selectExpr.(*sqlparser.AliasedExpr)
+		if !ok || aliased.As.IsEmpty() {
+			continue
+		}
+		funcExpr, ok := aliased.Expr.(*sqlparser.FuncExpr)
+		if !ok || funcExpr.Name.Lowered() != "histogram" {
+			continue
+		}
+		for _, expr := range selectStmt.GroupBy {
+			if column, ok := expr.(*sqlparser.ColName); ok && column.Name.Equal(aliased.As) {
+				timeBucketed = true
+				if interval, ok := histogramInterval(funcExpr); ok {
+					timeBucketColumn, timeBucketInterval = aliased.As.String(), interval
+				}
+			}
+		}
+	}
+	return aggregated, timeBucketed, timeBucketColumn, timeBucketInterval
+}
+
+// mergeableAggregates are the aggregate functions whose results over consecutive time ranges can
+// be combined into the result over the whole range, see combineAggregates
+var mergeableAggregates = map[string]bool{
+	"count": true,
+	"sum":   true,
+	"min":   true,
+	"max":   true,
+}
+
+// extractAggregatesFromSql returns the aggregate function of every selected column of a GROUP BY
+// SQL which is not a GROUP BY column, using sqlparser. Nil is returned when the SQL is not grouped or
+// when a column is neither grouped nor an aliased count, sum, min or max without DISTINCT, so that
+// its groups over consecutive time ranges cannot be merged.
+func extractAggregatesFromSql(sqlStr string) map[string]string {
+	stmt, err := sqlparser.Parse(sqlStr)
+	if err != nil {
+		return nil
+	}
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok || len(selectStmt.GroupBy) == 0 {
+		return nil
+	}
+
+	grouped := func(aliased *sqlparser.AliasedExpr) bool {
+		for _, expr := range selectStmt.GroupBy {
+			if column, ok := expr.(*sqlparser.ColName); ok && !aliased.As.IsEmpty() && column.Name.Equal(aliased.As) {
+				return true
+			}
+			if sqlparser.String(expr) == sqlparser.String(aliased.Expr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	aggregates := make(map[string]string)
+	for _, selectExpr := range selectStmt.SelectExprs {
+		aliased, ok := selectExpr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil // SELECT * of a grouped SQL
+		}
+		if grouped(aliased) {
+			continue
+		}
+		funcExpr, ok := aliased.Expr.(*sqlparser.FuncExpr)
+		if !ok || aliased.As.IsEmpty() || funcExpr.Distinct || !mergeableAggregates[funcExpr.Name.Lowered()] {
+			return nil
+		}
+		aggregates[aliased.As.String()] = funcExpr.Name.Lowered()
+	}
+	return aggregates
+}
+
+// histogramInterval returns the explicit interval of a histogram(_timestamp, '<interval>') call,
+// e.g. '1 minute', '30 seconds' or '1h'
+func histogramInterval(funcExpr *sqlparser.FuncExpr) (time.Duration, bool) {
+	if len(funcExpr.Exprs) < 2 {
+		return 0, false
+	}
+	aliased, ok := funcExpr.Exprs[1].(*sqlparser.AliasedExpr)
+	if !ok {
+		return 0, false
+	}
+	value, ok := aliased.Expr.(*sqlparser.SQLVal)
+	if !ok || value.Type != sqlparser.StrVal {
+		return 0, false
+	}
+
+	interval := strings.TrimSpace(string(value.Val))
+	digits := strings.IndexFunc(interval, func(r rune) bool { return r < '0' || r > '9' })
+	if digits <= 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(interval[:digits])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	unit := strings.ToLower(strings.TrimSpace(interval[digits:]))
+	if len(unit) > 2 {
+		unit = strings.TrimSuffix(unit, "s") // plural
+	}
+	switch unit {
+	case "s", "sec", "second":
+		return time.Duration(n) * time.Second, true
+	case "m", "min", "minute":
+		return time.Duration(n) * time.Minute, true
+	case "h", "hr", "hour":
+		return time.Duration(n) * time.Hour, true
+	case "d", "day":
+		return time.Duration(n) * 24 * time.Hour, true
+	case "w", "week":
+		return time.Duration(n) * 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
@@ -0,0 +1,194 @@
+package openobserve
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// events sent by the OpenObserve _search_stream API
+const (
+	sseEventHits     = "search_response_hits"
+	sseEventMetadata = "search_response_metadata"
+	sseEventProgress = "progress"
+	sseEventError    = "error"
+	sseEventCancel   = "cancelled"
+	sseEventEnd      = "end"
+)
+
+// ErrSearchCancelled is returned when OpenObserve reports that a streamed search was cancelled
+var ErrSearchCancelled = errors.New("search was cancelled by OpenObserve")
+
+// SearchStreamEvent is a partial result of a streamed search
+type SearchStreamEvent struct {
+	Hits     []map[string]any // hits received since the previous event, empty for progress events
+	Progress int              // completion percentage of the search as last reported by OpenObserve
+	Metadata *SearchResponse  // metadata of the search merged so far, without hits
+}
+
+// SearchStreamHandler is called for every partial result of a streamed search.
+// Returning an error aborts the search.
+type SearchStreamHandler func(event *SearchStreamEvent) error
+
+// sseEvent is a server-sent event as defined by https://html.spec.whatwg.org/multipage/server-sent-events.html
+type sseEvent struct {
+	Event string // event type, "message" if the stream does not name it
+	Data  string // data lines joined by "\n"
+	ID    string // last event id seen on the stream
+}
+
+// sseDecoder reads server-sent events from a stream
+type sseDecoder struct {
+	reader *bufio.Reader
+	lastID string
+}
+
+func newSSEDecoder(r io.Reader) *sseDecoder {
+	return &sseDecoder{reader: bufio.NewReader(r)}
+}
+
+// Next returns the next event of the stream, or io.EOF once the stream is exhausted.
+// An event which is not terminated by a blank line before the end of the stream is discarded.
+func (d *sseDecoder) Next() (*sseEvent, error) {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+	)
+	for {
+		line, err := d.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		// a blank line dispatches the event, events without data are ignored
+		if line == "" {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return &sseEvent{Event: eventType, Data: data.String(), ID: d.lastID}, nil
+		}
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		// comment, e.g. a keep-alive
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				d.lastID = value
+			}
+		}
+		// retry and unknown fields are ignored
+	}
+}
+
+// searchStreamMetadata is the payload of a search_response_metadata event
+type searchStreamMetadata struct {
+	Results *SearchResponse `json:"results"`
+}
+
+// searchStreamProgress is the payload of a progress event
+type searchStreamProgress struct {
+	Percent int `json:"percent"`
+}
+
+// decodeMetadata decodes the payload of a search_response_metadata event. The search response is
+// wrapped in "results" by recent OpenObserve versions and sent as is by older ones.
+func decodeMetadata(data string) (*SearchResponse, error) {
+	var metadata searchStreamMetadata
+	if err := sonic.UnmarshalString(data, &metadata); err != nil {
+		return nil, fmt.Errorf("invalid search metadata event: %w", err)
+	}
+	if metadata.Results != nil {
+		return metadata.Results, nil
+	}
+	var searchResponse SearchResponse
+	if err := sonic.UnmarshalString(data, &searchResponse); err != nil {
+		return nil, fmt.Errorf("invalid search metadata event: %w", err)
+	}
+	return &searchResponse, nil
+}
+
+// decodeStreamError turns the payload of an error event into an APIError. The stream itself was
+// answered with 200, the error code is used as the status code only when it is an HTTP status, as
+// OpenObserve also reports application codes such as 20009.
+func decodeStreamError(data string) error {
+	apiErr := &APIError{StatusCode: http.StatusInternalServerError}
+	if err := sonic.UnmarshalString(data, apiErr); err != nil || (apiErr.Message == "" && apiErr.ErrorDetail == "") {
+		apiErr.Body = strings.TrimSpace(data)
+	}
+	if apiErr.Code >= 100 && apiErr.Code <= 599 {
+		apiErr.StatusCode = apiErr.Code
+	}
+	return apiErr
+}
+
+// mergeMetadata merges the metadata of a (partial) search into searchResponse.
+// Each partition of a streamed search reports its own statistics, which add up.
+func (searchResponse *SearchResponse) mergeMetadata(metadata *SearchResponse) {
+	searchResponse.Took += metadata.Took
+	searchResponse.TookDetail.Total += metadata.TookDetail.Total
+	searchResponse.TookDetail.CacheTook += metadata.TookDetail.CacheTook
+	searchResponse.TookDetail.FileListTook += metadata.TookDetail.FileListTook
+	searchResponse.TookDetail.WaitInQueue += metadata.TookDetail.WaitInQueue
+	searchResponse.TookDetail.IdxTook += metadata.TookDetail.IdxTook
+	searchResponse.TookDetail.SearchTook += metadata.TookDetail.SearchTook
+	searchResponse.Total += metadata.Total
+	searchResponse.ScanSize += metadata.ScanSize
+	searchResponse.IdxScanSize += metadata.IdxScanSize
+	searchResponse.ScanRecords += metadata.ScanRecords
+	searchResponse.IsPartial = searchResponse.IsPartial || metadata.IsPartial
+
+	// ratios and identifiers are reported for the whole search, keep the latest
+	searchResponse.CachedRatio = metadata.CachedRatio
+	searchResponse.ResultCacheRatio = metadata.ResultCacheRatio
+	if metadata.From != 0 || metadata.Size != 0 {
+		searchResponse.From, searchResponse.Size = metadata.From, metadata.Size
+	}
+	if metadata.TraceID != "" {
+		searchResponse.TraceID = metadata.TraceID
+	}
+	if metadata.WorkGroup != "" {
+		searchResponse.WorkGroup = metadata.WorkGroup
+	}
+	if metadata.OrderBy != "" {
+		searchResponse.OrderBy = metadata.OrderBy
+	}
+}
+
+// decodeHits decodes the payload of a search_response_hits event
+func decodeHits(data string) ([]map[string]any, error) {
+	var partSearchResp SearchResponse
+	if err := sonic.ConfigDefault.NewDecoder(bytes.NewBufferString(data)).Decode(&partSearchResp); err != nil {
+		return nil, fmt.Errorf("invalid search hits event: %w", err)
+	}
+	return partSearchResp.Hits, nil
+}
@@ -0,0 +1,44 @@
+package openobserve
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDAttribute is the span attribute holding the trace id of a search on the OpenObserve side,
+// which lets a slow query be followed into the OpenObserve query traces
+const TraceIDAttribute = "openobserve.trace_id"
+
+// startSearchSpan starts the span of a search with the attributes of its request
+func startSearchSpan(ctx context.Context, name string, searchReqParam *SearchRequestParam, searchReqBody *SearchRequestBody) (context.Context, trace.Span) {
+	return tracing.DefaultTracer().Start(ctx, name, trace.WithAttributes(
+		attribute.String("openobserve.organization", searchReqParam.Organization),
+		attribute.String("openobserve.stream_type", searchReqParam.StreamType),
+		attribute.Bool("openobserve.sse", searchReqParam.EnableSSE),
+		attribute.String("db.query.text", searchReqBody.Sql),
+		attribute.Int64("openobserve.start_time", searchReqBody.StartTime),
+		attribute.Int64("openobserve.end_time", searchReqBody.EndTime),
+		attribute.Int64("openobserve.from", searchReqBody.From),
+		attribute.Int64("openobserve.size", searchReqBody.Size),
+	))
+}
+
+// endSearchSpan records the outcome of a search on its span and ends it
+func endSearchSpan(span trace.Span, searchResponse *SearchResponse, err error) {
+	defer span.End()
+	if err != nil {
+		tracing.Error(span, err)
+		return
+	}
+	span.SetAttributes(
+		attribute.String(TraceIDAttribute, searchResponse.TraceID),
+		attribute.Int("openobserve.hits", len(searchResponse.Hits)),
+		attribute.Int("openobserve.total", searchResponse.Total),
+		attribute.Int("openobserve.took_ms", searchResponse.Took),
+		attribute.Int("openobserve.scan_records", searchResponse.ScanRecords),
+		attribute.Bool("openobserve.is_partial", searchResponse.IsPartial),
+	)
+}
@@ -2,6 +2,7 @@ package openobserve
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"sort"
 	"strings"
@@ -34,6 +35,36 @@ func NewTransformer() *Transformer {
 	return &Transformer{}
 }
 
+// Query formats, the shape of the data frame a query is transformed into, see Transformer.Transform
+const (
+	FormatAuto       = "auto"        // logs for SELECT *, a table otherwise
+	FormatLogs       = "logs"        // time, body and labels of every row
+	FormatTable      = "table"       // a field per column
+	FormatTimeSeries = "time_series" // a wide time series, a series per combination of string columns
+)
+
+// IsFormat reports whether format is a known query format, the empty format is FormatAuto
+func IsFormat(format string) bool {
+	switch format {
+	case "", FormatAuto, FormatLogs, FormatTable, FormatTimeSeries:
+		return true
+	}
+	return false
+}
+
+// Transform transforms the OpenObserve search response into a Grafana data frame of the given format
+func (t *Transformer) Transform(parsedSql *SQL, searchResponse *SearchResponse, format string) (*data.Frame, error) {
+	switch format {
+	case FormatLogs:
+		return t.TransformLogs(searchResponse)
+	case FormatTable:
+		return t.TransformTable(parsedSql, searchResponse)
+	case FormatTimeSeries:
+		return t.TransformTimeSeries(parsedSql, searchResponse)
+	}
+	return t.TransformStream(parsedSql, searchResponse)
+}
+
 // TransformsStream transforms the OpenObserve search stream response into Grafana data frame
 func (t *Transformer) TransformStream(parsedSql *SQL, searchResponse *SearchResponse) (*data.Frame, error) {
 	if parsedSql.selectMode == SqlSelectALlColumns {
@@ -52,6 +83,62 @@ func (t *Transformer) TransformStream(parsedSql *SQL, searchResponse *SearchResp
 	return buildGraphModeDataFrame(tableResult)
 }
 
+// TransformLogs transforms the OpenObserve search response into a Grafana logs data frame,
+// whatever columns are selected
+func (t *Transformer) TransformLogs(searchResponse *SearchResponse) (*data.Frame, error) {
+	parsedSearchResult, err := parseSearchResponse(searchResponse)
+	if err != nil {
+		return nil, err
+	}
+	return buildLogModeDataFrame(parsedSearchResult)
+}
+
+// TransformTable transforms the OpenObserve search response into a Grafana table data frame with a
+// field per selected column, every column of the hits for SELECT *. The _timestamp column, the
+// histogram column and the columns whose name contains gf_time become time fields.
+func (t *Transformer) TransformTable(parsedSql *SQL, searchResponse *SearchResponse) (*data.Frame, error) {
+	columns := parsedSql.selectColumns
+	if parsedSql.selectMode == SqlSelectALlColumns || len(columns) == 0 {
+		columns = hitsColumns(searchResponse.Hits)
+	}
+	tableResult, err := parseSearchResponseToTable(columns, searchResponse)
+	if err != nil {
+		return nil, err
+	}
+	frame, err := buildDataFrame(tableResult, func(column string) bool {
+		return isGfTimeColumn(column) || column == timestampColumn || column == parsedSql.TimeBucketColumn
+	})
+	if err != nil {
+		return nil, err
+	}
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	return frame, nil
+}
+
+// TransformTimeSeries transforms the OpenObserve search response into a Grafana wide time series
+// data frame. The time index is the first time column, see TransformTable, the numeric columns
+// become series labelled with the values of the string and bool columns of their rows.
+func (t *Transformer) TransformTimeSeries(parsedSql *SQL, searchResponse *SearchResponse) (*data.Frame, error) {
+	frame, err := t.TransformTable(parsedSql, searchResponse)
+	if err != nil {
+		return nil, err
+	}
+	frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide, PreferredVisualization: data.VisTypeGraph}
+	if len(searchResponse.Hits) == 0 {
+		return frame, nil
+	}
+
+	timeIndices := frame.TypeIndices(data.FieldTypeTime)
+	if len(timeIndices) == 0 {
+		return nil, fmt.Errorf("the time series format requires a time column: select _timestamp, or alias the time column with a name containing gf_time")
+	}
+	sortFrameByTime(frame, timeIndices[0])
+	if len(frame.TypeIndices(data.FieldTypeString, data.FieldTypeBool)) == 0 {
+		return frame, nil
+	}
+	return data.LongToWide(frame, nil)
+}
+
 // TransformFallbackDisplayTables transforms the OpenObserve list streams response into Grafana data frame
 // This is used when the user selects a stream from the dropdown in the query editor
 func (t *Transformer) TransformFallbackDisplayTables(listStreamResp *ListStreamResponse) (*data.Frame, error) {
@@ -86,6 +173,51 @@ func (t *Transformer) TransformFallbackSelectFrom(parsedSql *SQL, searchResponse
 	return buildGraphModeDataFrame(tableResult)
 }
 
+// SetSearchMeta records in the meta of frame what OpenObserve did to answer a search, so that the
+// query inspector shows it: the executed SQL, the search statistics, a warning when the results are
+// partial and the number of rows returned out of the matching ones
+func (t *Transformer) SetSearchMeta(frame *data.Frame, sql string, searchResponse *SearchResponse) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	frame.Meta.ExecutedQueryString = sql
+	frame.Meta.Stats = append(frame.Meta.Stats, searchStats(searchResponse)...)
+
+	if searchResponse.IsPartial {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "Partial results: OpenObserve could not search all of the data, e.g. because the search timed out",
+		})
+	}
+	text := fmt.Sprintf("OpenObserve returned %d of %d matching rows", len(searchResponse.Hits), searchResponse.Total)
+	if searchResponse.TraceID != "" {
+		text += fmt.Sprintf(", trace id %s", searchResponse.TraceID)
+	}
+	frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityInfo, Text: text})
+}
+
+// searchStats returns the statistics of a search reported by OpenObserve. Times are in
+// milliseconds and scan sizes in megabytes.
+func searchStats(searchResponse *SearchResponse) []data.QueryStat {
+	stat := func(name, unit string, value int) data.QueryStat {
+		return data.QueryStat{FieldConfig: data.FieldConfig{DisplayName: name, Unit: unit}, Value: float64(value)}
+	}
+	return []data.QueryStat{
+		stat("Total hits", "short", searchResponse.Total),
+		stat("Took", "ms", searchResponse.Took),
+		stat("OpenObserve queue time", "ms", searchResponse.TookDetail.WaitInQueue),
+		stat("Cache lookup time", "ms", searchResponse.TookDetail.CacheTook),
+		stat("File list time", "ms", searchResponse.TookDetail.FileListTook),
+		stat("Index search time", "ms", searchResponse.TookDetail.IdxTook),
+		stat("Search time", "ms", searchResponse.TookDetail.SearchTook),
+		stat("Scan size", "decmbytes", searchResponse.ScanSize),
+		stat("Index scan size", "decmbytes", searchResponse.IdxScanSize),
+		stat("Scanned records", "short", searchResponse.ScanRecords),
+		stat("Cached ratio", "percent", searchResponse.CachedRatio),
+		stat("Result cache ratio", "percent", searchResponse.ResultCacheRatio),
+	}
+}
+
 func parseSearchResponse(searchResponse *SearchResponse) (*ParsedSearchResult, error) {
 	Items := make([]Item, 0, len(searchResponse.Hits))
 
@@ -134,6 +266,9 @@ func parseSearchResponseToTable(columns []string, searchResponse *SearchResponse
 	// fill the table with data from searchResponse.hit
 	for i, hit := range searchResponse.Hits {
 		for key, value := range hit {
+			if value == nil {
+				continue // filled with the zero value of the column below
+			}
 			table[key] = append(table[key], value)
 		}
 
@@ -189,7 +324,61 @@ func buildLogModeDataFrame(parsedSearchResult *ParsedSearchResult) (*data.Frame,
 }
 
 func buildGraphModeDataFrame(tableResult *TableResult) (*data.Frame, error) {
+	return buildDataFrame(tableResult, isGfTimeColumn)
+}
+
+// timestampColumn is the column holding the ingestion time of a row, in microseconds
+const timestampColumn = "_timestamp"
+
+// isGfTimeColumn reports whether a column is named to be converted to a time field
+func isGfTimeColumn(column string) bool {
+	return strings.Contains(column, "gf_time")
+}
+
+// hitsColumns returns the columns of hits, _timestamp first then by name
+func hitsColumns(hits []map[string]any) []string {
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, hit := range hits {
+		for column := range hit {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if (columns[i] == timestampColumn) != (columns[j] == timestampColumn) {
+			return columns[i] == timestampColumn
+		}
+		return columns[i] < columns[j]
+	})
+	return columns
+}
+
+// sortFrameByTime sorts the rows of frame by its time field at timeIndex, ascending
+func sortFrameByTime(frame *data.Frame, timeIndex int) {
+	times := frame.Fields[timeIndex]
+	order := make([]int, times.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return times.At(order[i]).(time.Time).Before(times.At(order[j]).(time.Time))
+	})
+	for i, field := range frame.Fields {
+		sorted := data.NewFieldFromFieldType(field.Type(), field.Len())
+		sorted.Name, sorted.Labels, sorted.Config = field.Name, field.Labels, field.Config
+		for row, from := range order {
+			sorted.Set(row, field.At(from))
+		}
+		frame.Fields[i] = sorted
+	}
+}
 
+// buildDataFrame builds a data frame with a field per column of tableResult, the columns for which
+// isTime returns true are converted to time fields
+func buildDataFrame(tableResult *TableResult, isTime func(column string) bool) (*data.Frame, error) {
 	frame := data.NewFrame("openobserve_data_frame")
 	for _, header := range tableResult.Headers {
 		if len(tableResult.Table[header]) == 0 {
@@ -198,7 +387,7 @@ func buildGraphModeDataFrame(tableResult *TableResult) (*data.Frame, error) {
 		columnElemType := reflect.TypeOf(tableResult.Table[header][0])
 
 		// Special handling for timestamp fields: convert to int64 timestamp format required by Grafana
-		if strings.Contains(header, "gf_time") {
+		if isTime(header) {
 			timestampVec := make([]time.Time, 0, len(tableResult.Table[header]))
 			for _, v := range tableResult.Table[header] {
 				switch v := v.(type) {
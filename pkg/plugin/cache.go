@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// cacheEntryOverhead approximates the memory used by a cache entry besides its hits
+const cacheEntryOverhead = 512
+
+// resultCache is an LRU cache of search responses bounded by the size of their decoded hits.
+// Cached responses are shared and must not be modified.
+type resultCache struct {
+	ttl      time.Duration
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // key --> element of lru holding a *cacheEntry
+	lru     *list.List               // most recently used first
+	bytes   int64                    // size of the cached entries
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// cachedSearch is a cached search response with the time range it covers
+type cachedSearch struct {
+	searchResponse *openobserve.SearchResponse
+	startTime      int64 // microseconds
+	endTime        int64 // microseconds
+}
+
+type cacheEntry struct {
+	key       string
+	search    *cachedSearch
+	bytes     int64
+	expiresAt time.Time
+}
+
+func newResultCache(ttl time.Duration, maxBytes int64) *resultCache {
+	return &resultCache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// cacheKey identifies the result of a search. The identity of the user is part of the key when
+// the search runs with the forwarded user credentials, users may not see the same data.
+func cacheKey(identity string, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d\x00%d\x00%d\x00%d",
+		identity, searchReqParam.Organization, searchReqParam.StreamType, searchReqBody.Sql,
+		searchReqBody.StartTime, searchReqBody.EndTime, searchReqBody.From, searchReqBody.Size)
+}
+
+// incrementalKey identifies the searches of a query whatever their time range, see Datasource.incrementalSearch
+func incrementalKey(identity string, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d\x00%d",
+		identity, searchReqParam.Organization, searchReqParam.StreamType, searchReqBody.Sql, searchReqBody.From, searchReqBody.Size)
+}
+
+// get returns the cached search of key, if any and not expired
+func (c *resultCache) get(key string) (*cachedSearch, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.search, true
+}
+
+// set caches search under key, evicting the least recently used entries to stay within the size
+// bound. Partial results and responses larger than the whole cache are not cached.
+func (c *resultCache) set(key string, search *cachedSearch) {
+	if search.searchResponse.IsPartial {
+		return
+	}
+	bytes := cacheEntryOverhead + int64(len(key)) + hitsSize(search.searchResponse.Hits)
+	if bytes > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:       key,
+		search:    search,
+		bytes:     bytes,
+		expiresAt: time.Now().Add(c.ttl),
+	})
+	c.bytes += bytes
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an entry, c.mu must be held
+func (c *resultCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.bytes
+}
+
+// stats returns the cache statistics reported in the frame meta
+func (c *resultCache) stats(hit bool) []data.QueryStat {
+	c.mu.Lock()
+	bytes := c.bytes
+	c.mu.Unlock()
+
+	status := 0.0
+	if hit {
+		status = 1
+	}
+	return []data.QueryStat{
+		{FieldConfig: data.FieldConfig{DisplayName: "Cache hit"}, Value: status},
+		{FieldConfig: data.FieldConfig{DisplayName: "Cache hits"}, Value: float64(c.hits.Load())},
+		{FieldConfig: data.FieldConfig{DisplayName: "Cache misses"}, Value: float64(c.misses.Load())},
+		{FieldConfig: data.FieldConfig{DisplayName: "Cache size", Unit: "decbytes"}, Value: float64(bytes)},
+	}
+}
+
+// hitsSize approximates the memory used by decoded hits
+func hitsSize(hits []map[string]any) int64 {
+	var size int64
+	for _, hit := range hits {
+		size += valueSize(hit)
+	}
+	return size
+}
+
+func valueSize(value any) int64 {
+	const overhead = 16 // interface header
+	switch value := value.(type) {
+	case string:
+		return overhead + int64(len(value))
+	case map[string]any:
+		size := int64(overhead + 48)
+		for key, v := range value {
+			size += overhead + int64(len(key)) + valueSize(v)
+		}
+		return size
+	case []any:
+		size := int64(overhead + 24)
+		for _, v := range value {
+			size += valueSize(v)
+		}
+		return size
+	}
+	return overhead + 8 // numbers, booleans and null
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"math/rand"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/LinPr/grafana-openobserve-datasource/pkg/models"
 	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Make sure Datasource implements required interfaces. This is important to do
@@ -23,37 +28,82 @@ var (
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 	_ backend.CallResourceHandler   = (*Datasource)(nil)
+	_ backend.StreamHandler         = (*Datasource)(nil)
+	_ backend.CollectMetricsHandler = (*Datasource)(nil)
 )
 
 // Datasource is an example datasource which can respond to data queries, reports
 // its health and has streaming skills.
 type Datasource struct {
-	connectionID      int
-	openObserveClient *openobserve.OpenObserveClient
-	SqlParser         *openobserve.SqlParser
-	transformer       *openobserve.Transformer
-	resourceHandler   backend.CallResourceHandler
-	queryHandler      backend.QueryDataHandler
+	connectionID         int
+	openObserveClient    *openobserve.OpenObserveClient
+	SqlParser            *openobserve.SqlParser
+	transformer          *openobserve.Transformer
+	resourceHandler      backend.CallResourceHandler
+	queryHandler         backend.QueryDataHandler
+	queryTimeout         time.Duration  // default timeout of a query, see grafanaQueryModel.Timeout
+	organization         string         // OpenObserve organization configured as the datasource database
+	searchStreams        *searchStreams // searches streamed to panels through Grafana Live
+	liveTailInterval     time.Duration  // time between two polls of a live tail
+	liveTailRateLimit    int            // maximum number of rows per second published by a live tail
+	partitionMinRange    time.Duration  // time range above which searches are partitioned by default
+	partitionConcurrency int            // partitions of a search run concurrently
+	maxRows              int64          // maximum number of rows returned by a query
+	resultCache          *resultCache   // cache of search results, nil if disabled
+	incrementalOverlap   time.Duration  // part of the previous time range searched again by an incremental query
+	incrementalResults   *resultCache   // previous results of incremental queries, by incrementalKey
+	lastResults          *resultCache   // last result of each query served while OpenObserve is unavailable, nil if disabled
 }
 
 // NewDatasource creates a new datasource instance.
-func NewDatasource(_ context.Context, req backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+func NewDatasource(ctx context.Context, req backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 	config, err := models.LoadPluginSettings(req)
 	if err != nil {
 		return nil, err
 	}
-	openobserveClient := openobserve.NewOpenObserveClient(config.Url, config.Username, config.DecryptedSecureJSONData.Password)
+	httpClient, err := newHTTPClient(ctx, req, config)
+	if err != nil {
+		return nil, err
+	}
+	openobserveClient := openobserve.NewOpenObserveClient(config.Url, openobserve.ClientOptions{
+		Auth:        config.Auth(),
+		HTTPClient:  httpClient,
+		RetryPolicy: config.RetryPolicy(),
+		Timeout:     config.QueryTimeout(),
+
+		CircuitBreaker:     config.CircuitBreakerPolicy(),
+		ForwardHTTPHeaders: config.ForwardHTTPHeaders(),
+
+		MaxConcurrentRequests: config.MaxConcurrentQueries(),
+		QueryTypeConcurrency:  config.QueryTypeConcurrency(),
+	})
 
 	// adapterMux is a HTTP request multiplexer that handles resource requests.
 	adapterMux := http.NewServeMux()
 	adapterMux.Handle("/openobserve/streams", http.HandlerFunc(openobserveClient.HandleListStreams))
 
 	ds := &Datasource{
-		connectionID:      rand.Intn(1000000),
-		openObserveClient: openobserveClient,
-		SqlParser:         openobserve.NewSqlParser(),
-		transformer:       openobserve.NewTransformer(),
-		resourceHandler:   httpadapter.New(adapterMux),
+		connectionID:         rand.Intn(1000000),
+		openObserveClient:    openobserveClient,
+		SqlParser:            openobserve.NewSqlParser(),
+		transformer:          openobserve.NewTransformer(),
+		resourceHandler:      httpadapter.New(adapterMux),
+		queryTimeout:         config.QueryTimeout(),
+		organization:         config.JsonData.Database,
+		searchStreams:        newSearchStreams(),
+		liveTailInterval:     config.LiveTailInterval(),
+		liveTailRateLimit:    config.LiveTailRateLimit(),
+		partitionMinRange:    config.PartitionMinRange(),
+		partitionConcurrency: config.PartitionConcurrency(),
+		maxRows:              config.MaxRows(),
+		incrementalOverlap:   config.IncrementalOverlap(),
+		incrementalResults:   newResultCache(incrementalResultExpiry, config.CacheMaxSize()),
+	}
+	if config.JsonData.ServeStaleResults {
+		ds.lastResults = newResultCache(staleResultExpiry, config.CacheMaxSize())
+	}
+	if !config.JsonData.DisableCache {
+		ds.resultCache = newResultCache(config.CacheTTL(), config.CacheMaxSize())
 	}
 
 	//queryTypes multiplexer, automatically dispatches requests to the appropriate handler based on the queryType in request.
@@ -61,39 +111,81 @@ func NewDatasource(_ context.Context, req backend.DataSourceInstanceSettings) (i
 	return ds, nil
 }
 
+// newHTTPClient creates the HTTP client used to talk to OpenObserve from the standard datasource
+// HTTP settings (proxy, secure socks proxy, custom headers, timeouts, forwarded headers and cookies)
+func newHTTPClient(ctx context.Context, settings backend.DataSourceInstanceSettings, config *models.PluginSettings) (*http.Client, error) {
+	opts, err := settings.HTTPClientOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// credentials are applied per request by the OpenObserve client according to the auth mode,
+	// the SDK basic auth middleware would otherwise override bearer and header auth
+	opts.BasicAuth = nil
+	if tlsOptions := config.TLSOptions(); tlsOptions != nil {
+		opts.TLS = tlsOptions
+	}
+	// deadlines are set per request from the datasource and query timeouts, a client wide timeout
+	// would cap queries which are allowed to run longer than the datasource default. Every request
+	// of the OpenObserve client is bounded by a context deadline instead, see OpenObserveClient.withTimeout.
+	opts.Timeouts.Timeout = 0
+	// forward the ID token and the cookies Grafana sends with OAuth pass-through or allowed cookies, the
+	// Authorization header is set by the OpenObserve client itself, see OpenObserveClient.WithForwardedHeaders
+	opts.ForwardHTTPHeaders = opts.ForwardHTTPHeaders || config.ForwardHTTPHeaders()
+
+	return httpclient.NewProvider().New(opts)
+}
+
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance
 // created. As soon as datasource settings change detected by SDK old datasource instance will
 // be disposed and a new one will be created using NewSampleDatasource factory function.
 func (d *Datasource) Dispose() {
-	// Clean up datasource instance resources.
+	d.searchStreams.close()
 }
 
 // QueryData query data source
 func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	alerting := isAlertingRequest(req.Headers)
+	if alerting {
+		ctx = openobserve.WithAlerting(ctx)
+	}
+	ctx, span := tracing.DefaultTracer().Start(ctx, "QueryData", trace.WithAttributes(
+		attribute.Int("queries", len(req.Queries)),
+		attribute.Bool("alerting", alerting),
+	))
+	defer span.End()
+
 	// dispatch the request to the appropriate handler based on the query type.
-	return d.queryHandler.QueryData(ctx, req)
+	resp, err := d.queryHandler.QueryData(ctx, req)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	for refID, dataResponse := range resp.Responses {
+		if dataResponse.Error != nil {
+			tracing.Errorf(span, "query %s: %w", refID, dataResponse.Error)
+		}
+	}
+	return resp, nil
+}
+
+// isAlertingRequest reports whether a request is sent by Grafana alerting, based on the headers Grafana sets
+func isAlertingRequest(headers map[string]string) bool {
+	for name, value := range headers {
+		if strings.EqualFold(name, "FromAlert") && value == "true" {
+			return true
+		}
+	}
+	return false
 }
 
 // CheckHealth handles health checks sent from Grafana to the plugin.
 // The main use case for these health checks is the test button on the
 // datasource configuration page which allows users to verify that
 // a datasource is working as expected.
-func (d *Datasource) CheckHealth(_ context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	log.DefaultLogger.Debug("CheckHealth callend", "request", req)
-
-	res := &backend.CheckHealthResult{}
-	// config, err := models.LoadPluginSettings(*req.PluginContext.DataSourceInstanceSettings)
-
-	if err := d.openObserveClient.HealthCheck(); err != nil {
-		res.Status = backend.HealthStatusError
-		res.Message = err.Error()
-		return res, nil
-	}
+func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	openobserve.Logger(ctx).Debug("CheckHealth called", "headers", openobserve.RedactHeaders(req.GetHTTPHeaders()))
 
-	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "Data source is working",
-	}, nil
+	return d.checkHealth(ctx, d.openObserveClient.WithForwardedHeaders(req.GetHTTPHeaders())), nil
 }
 
 func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
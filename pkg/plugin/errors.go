@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// statusCancelled is reported for queries cancelled by the client (nginx's "client closed request"),
+// the SDK has no dedicated status for it
+const statusCancelled backend.Status = 499
+
+// errorStatus returns the Grafana status and error source of an error returned while running a query.
+// OpenObserve API errors and network failures are attributed to the downstream service, anything
+// else to the plugin.
+func errorStatus(err error) (backend.Status, backend.ErrorSource) {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, openobserve.ErrSearchCancelled):
+		return statusCancelled, backend.ErrorSourceDownstream
+	case errors.Is(err, context.DeadlineExceeded):
+		return backend.StatusTimeout, backend.ErrorSourceDownstream
+	case errors.Is(err, openobserve.ErrCircuitOpen):
+		return backend.StatusBadGateway, backend.ErrorSourceDownstream
+	}
+
+	var apiErr *openobserve.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadRequest:
+			return backend.StatusBadRequest, backend.ErrorSourceDownstream // invalid SQL, unknown stream or field
+		case http.StatusUnauthorized:
+			return backend.StatusUnauthorized, backend.ErrorSourceDownstream
+		case http.StatusForbidden:
+			return backend.StatusForbidden, backend.ErrorSourceDownstream
+		case http.StatusNotFound:
+			return backend.StatusNotFound, backend.ErrorSourceDownstream
+		case http.StatusTooManyRequests:
+			return backend.StatusTooManyRequests, backend.ErrorSourceDownstream
+		case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+			return backend.StatusTimeout, backend.ErrorSourceDownstream
+		}
+		return backend.StatusBadGateway, backend.ErrorSourceDownstream
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return backend.StatusTimeout, backend.ErrorSourceDownstream
+		}
+		return backend.StatusBadGateway, backend.ErrorSourceDownstream
+	}
+	if backend.IsDownstreamHTTPError(err) {
+		return backend.StatusBadGateway, backend.ErrorSourceDownstream
+	}
+
+	return backend.StatusInternal, backend.ErrorSourcePlugin
+}
+
+// errDataResponse turns an error of a query into a data response with the matching status and error source
+func errDataResponse(err error, message string) backend.DataResponse {
+	status, source := errorStatus(err)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return backend.ErrDataResponseWithSource(status, source, fmt.Sprintf("%s: query cancelled", message))
+	case errors.Is(err, context.DeadlineExceeded):
+		return backend.ErrDataResponseWithSource(status, source, fmt.Sprintf("%s: query timed out", message))
+	}
+	return backend.ErrDataResponseWithSource(status, source, fmt.Sprintf("%s: %v", message, err.Error()))
+}
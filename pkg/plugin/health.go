@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// healthDetails is reported as the JSON details of a health check
+type healthDetails struct {
+	Version               string         `json:"version,omitempty"`
+	Organization          string         `json:"organization"`
+	Streams               map[string]int `json:"streams"`               // stream type --> number of streams
+	SearchStreamAvailable *bool          `json:"searchStreamAvailable"` // null if unknown
+	Warnings              []string       `json:"warnings,omitempty"`
+}
+
+// checkHealth verifies the credentials, the configured organization and the stream permissions,
+// and detects the OpenObserve version and whether the _search_stream API is available
+func (d *Datasource) checkHealth(ctx context.Context, client *openobserve.OpenObserveClient) *backend.CheckHealthResult {
+	details := &healthDetails{
+		Organization: d.organization,
+		Streams:      make(map[string]int, 3),
+	}
+
+	if err := client.HealthCheck(ctx); err != nil {
+		return healthError(details, describeHealthError(client.BaseUrl, err))
+	}
+
+	if serverConfig, err := client.GetConfig(ctx); err != nil {
+		details.Warnings = append(details.Warnings, fmt.Sprintf("could not detect the OpenObserve version: %v", err))
+	} else {
+		details.Version = serverConfig.Version
+	}
+
+	if d.organization == "" {
+		return healthError(details, "Organization is not configured, set the OpenObserve organization in the datasource settings")
+	}
+	if organizations, err := client.ListOrganizations(ctx); err != nil {
+		details.Warnings = append(details.Warnings, fmt.Sprintf("could not verify the organization: %v", err))
+	} else {
+		identifiers := make([]string, 0, len(organizations.Data))
+		for _, organization := range organizations.Data {
+			identifiers = append(identifiers, organization.Identifier)
+		}
+		if !slices.Contains(identifiers, d.organization) {
+			return healthError(details, fmt.Sprintf("Organization %q not found or the user is not a member of it, available organizations: %s",
+				d.organization, strings.Join(identifiers, ", ")))
+		}
+	}
+
+	streamTypes := []string{openobserve.LogsStream, openobserve.MetricStream, openobserve.TraceStream}
+	var listErrs []string
+	for _, streamType := range streamTypes {
+		listStreamResp, err := client.ListStreams(ctx, &openobserve.ListStreamRequestParam{
+			Organization: d.organization,
+			StreamType:   streamType,
+			SortBy:       "name",
+			Ascending:    true,
+		})
+		if err != nil {
+			listErrs = append(listErrs, fmt.Sprintf("cannot list %s streams: %s", streamType, describeHealthError(client.BaseUrl, err)))
+			continue
+		}
+		details.Streams[streamType] = len(listStreamResp.List)
+	}
+	if len(listErrs) == len(streamTypes) {
+		return healthError(details, fmt.Sprintf("No streams of organization %q can be listed: %s", d.organization, strings.Join(listErrs, "; ")))
+	}
+	details.Warnings = append(details.Warnings, listErrs...)
+
+	available, err := client.SearchStreamAvailable(ctx, d.organization)
+	if err != nil {
+		details.Warnings = append(details.Warnings, fmt.Sprintf("could not detect whether _search_stream is available: %v", err))
+	} else {
+		if !available {
+			details.Warnings = append(details.Warnings, "_search_stream is not available on this OpenObserve version, disable SSE in queries")
+		}
+		details.SearchStreamAvailable = &available
+	}
+
+	version := details.Version
+	if version == "" {
+		version = "unknown version"
+	}
+	message := fmt.Sprintf("Data source is working, OpenObserve %s, organization %q has %d logs, %d metrics and %d traces streams",
+		version, d.organization, details.Streams[openobserve.LogsStream], details.Streams[openobserve.MetricStream], details.Streams[openobserve.TraceStream])
+	if len(details.Warnings) > 0 {
+		message = fmt.Sprintf("%s. Warnings: %s", message, strings.Join(details.Warnings, "; "))
+	}
+
+	return &backend.CheckHealthResult{
+		Status:      backend.HealthStatusOk,
+		Message:     message,
+		JSONDetails: marshalHealthDetails(details),
+	}
+}
+
+// describeHealthError turns an error of the health check into an actionable message
+func describeHealthError(url string, err error) string {
+	var apiErr *openobserve.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusUnauthorized:
+			return fmt.Sprintf("Authentication failed, check the username and password or the token of the datasource: %v", err)
+		case http.StatusForbidden:
+			return fmt.Sprintf("Access denied, the OpenObserve user lacks the required permissions: %v", err)
+		}
+		return err.Error()
+	}
+	if status, source := errorStatus(err); source == backend.ErrorSourceDownstream && status != backend.StatusTimeout {
+		return fmt.Sprintf("OpenObserve is not reachable at %s, check the URL and the TLS and proxy settings: %v", url, err)
+	}
+	return err.Error()
+}
+
+func healthError(details *healthDetails, message string) *backend.CheckHealthResult {
+	return &backend.CheckHealthResult{
+		Status:      backend.HealthStatusError,
+		Message:     message,
+		JSONDetails: marshalHealthDetails(details),
+	}
+}
+
+func marshalHealthDetails(details *healthDetails) []byte {
+	b, _ := json.Marshal(details)
+	return b
+}
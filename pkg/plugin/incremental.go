@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+)
+
+// incrementalResultExpiry bounds how long the previous result of an incremental query is kept,
+// i.e. the longest refresh interval which benefits from incremental refreshes
+const incrementalResultExpiry = 15 * time.Minute
+
+// incrementalSearch runs the time-bucketed search of a query over the part of its time range
+// following the previous result of the same query, moved back by the configured overlap to pick up
+// late data, and merges the previous result in. The whole time range is searched the first time,
+// when the query bypasses the cache, and when the time range moved back or too far forward to overlap
+// with the previous one.
+func (ds *Datasource) incrementalSearch(ctx context.Context, client *openobserve.OpenObserveClient, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody, parsedSql *openobserve.SQL, gqm *grafanaQueryModel) (*openobserve.SearchResponse, *searchInfo, error) {
+	key := incrementalKey(client.Identity(), searchReqParam, searchReqBody)
+	previous, ok := ds.incrementalResults.get(key)
+	// the slice starts on a bucket boundary, its first bucket is complete and replaces the previous one
+	var sliceStart int64
+	if ok {
+		sliceStart = parsedSql.AlignToTimeBucket(previous.endTime - ds.incrementalOverlap.Microseconds())
+	}
+	if !ok || gqm.BypassCache || searchReqBody.StartTime < previous.startTime || searchReqBody.EndTime < previous.endTime || sliceStart <= searchReqBody.StartTime {
+		observeCache(ctx, "incremental", false)
+		searchResponse, searchInfo, err := ds.search(ctx, client, searchReqParam, searchReqBody, parsedSql, gqm)
+		if err != nil {
+			return nil, nil, err
+		}
+		ds.rememberIncremental(key, searchReqBody, searchResponse)
+		return searchResponse, searchInfo, nil
+	}
+
+	observeCache(ctx, "incremental", true)
+	sliceReqBody := *searchReqBody
+	sliceReqBody.StartTime = sliceStart
+	slice, searchInfo, err := ds.search(ctx, client, searchReqParam, &sliceReqBody, parsedSql, gqm)
+	if err != nil {
+		return nil, nil, err
+	}
+	searchResponse := openobserve.MergeTimeBuckets(parsedSql, previous.searchResponse, slice, searchReqBody.StartTime, sliceStart)
+	openobserve.Logger(ctx).Debug("Incremental search completed", "sliceStart", sliceReqBody.StartTime, "sliceHits", len(slice.Hits), "hits", len(searchResponse.Hits))
+	ds.rememberIncremental(key, searchReqBody, searchResponse)
+	return searchResponse, searchInfo, nil
+}
+
+// rememberIncremental keeps the result of an incremental query for its next refresh, unless it was
+// cut by the size of the search and misses buckets
+func (ds *Datasource) rememberIncremental(key string, searchReqBody *openobserve.SearchRequestBody, searchResponse *openobserve.SearchResponse) {
+	if int64(len(searchResponse.Hits)) >= searchReqBody.Size {
+		return
+	}
+	ds.incrementalResults.set(key, &cachedSearch{
+		searchResponse: searchResponse,
+		startTime:      searchReqBody.StartTime,
+		endTime:        searchReqBody.EndTime,
+	})
+}
@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/concurrent"
+	"golang.org/x/sync/errgroup"
+)
+
+// fallbackQueryType names the queries without a query type, e.g. dashboard variables, in the limits
+const fallbackQueryType = "fallback"
+
+// limited runs the queries of queryType with their requests to OpenObserve limited as queryType,
+// alerting queries first, and reports the time each query waited for the limiter in the meta of
+// its frames. The requests of the queries are labelled with queryType in the metrics, their log
+// lines identify the query and a summary line is logged once the query completes.
+func (ds *Datasource) limited(queryType string, fn concurrent.QueryDataFunc) concurrent.QueryDataFunc {
+	return func(ctx context.Context, query concurrent.Query) backend.DataResponse {
+		start := time.Now()
+		ctx = openobserve.WithQueryType(ctx, queryType)
+		ctx = withQueryLogAttributes(ctx, query)
+		ctx, summary := withQuerySummary(ctx)
+		ctx, wait := openobserve.WithQueueWait(ctx)
+
+		resp := fn(ctx, query)
+		for _, frame := range resp.Frames {
+			if frame.Meta == nil {
+				frame.Meta = &data.FrameMeta{}
+			}
+			frame.Meta.Stats = append(frame.Meta.Stats, data.QueryStat{
+				FieldConfig: data.FieldConfig{DisplayName: "Queue wait time", Unit: "ms"},
+				Value:       float64(wait.Duration().Microseconds()) / 1000,
+			})
+		}
+		summary.log(ctx, resp, time.Since(start), wait.Duration())
+		return resp
+	}
+}
+
+// queryData runs the queries of req through fn concurrently, as many at once as the requests of
+// queryType the client sends concurrently. concurrent.QueryData would cap them at 10 whatever the
+// configured limit.
+func (ds *Datasource) queryData(ctx context.Context, req *backend.QueryDataRequest, queryType string, fn concurrent.QueryDataFunc) (*backend.QueryDataResponse, error) {
+	fn = ds.limited(queryType, fn)
+	limit := ds.openObserveClient.ConcurrencyLimit(queryType)
+	if limit <= 0 {
+		limit = -1 // unlimited
+	}
+
+	headers := req.GetHTTPHeaders()
+	responses := make([]backend.DataResponse, len(req.Queries))
+	var g errgroup.Group
+	g.SetLimit(limit)
+	for i, q := range req.Queries {
+		g.Go(func() error {
+			defer func() {
+				if r := recover(); r != nil {
+					openobserve.Logger(ctx).Error("query datasource panic", "refId", q.RefID, "error", r)
+					responses[i] = backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("query panic: %v", r))
+				}
+			}()
+			responses[i] = fn(ctx, concurrent.Query{PluginContext: req.PluginContext, Headers: headers, DataQuery: q})
+			return nil
+		})
+	}
+	g.Wait()
+
+	resp := backend.NewQueryDataResponse()
+	for i, q := range req.Queries {
+		resp.Responses[q.RefID] = responses[i]
+	}
+	return resp, nil
+}
@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/concurrent"
+)
+
+// headers set by Grafana on the queries of a dashboard panel or an alert rule
+const (
+	dashboardUIDHeader = "X-Dashboard-Uid"
+	panelIDHeader      = "X-Panel-Id"
+	ruleUIDHeader      = "X-Rule-Uid"
+)
+
+// withQueryLogAttributes returns a copy of ctx whose logger identifies the query: its refID, the
+// Grafana org and the dashboard panel or alert rule it belongs to
+func withQueryLogAttributes(ctx context.Context, query concurrent.Query) context.Context {
+	args := []any{"refId", query.DataQuery.RefID, "queryType", query.DataQuery.QueryType, "orgId", query.PluginContext.OrgID}
+	for _, header := range []struct{ key, name string }{
+		{"dashboardUid", dashboardUIDHeader},
+		{"panelId", panelIDHeader},
+		{"ruleUid", ruleUIDHeader},
+	} {
+		if value := query.Headers.Get(header.name); value != "" {
+			args = append(args, header.key, value)
+		}
+	}
+	return openobserve.WithLogAttributes(ctx, args...)
+}
+
+// querySummary collects what a query did for its summary log line
+type querySummary struct {
+	searched  bool
+	traceID   string // trace id of the search on the OpenObserve side
+	cacheHit  bool
+	hits      int
+	total     int
+	took      time.Duration // search time reported by OpenObserve
+	scanSize  int           // megabytes
+	transform time.Duration
+}
+
+type querySummaryKey struct{}
+
+// withQuerySummary returns a copy of ctx collecting the summary of a query
+func withQuerySummary(ctx context.Context) (context.Context, *querySummary) {
+	summary := &querySummary{}
+	return context.WithValue(ctx, querySummaryKey{}, summary), summary
+}
+
+// querySummaryFromContext returns the summary of the query of ctx, which is discarded if there is none
+func querySummaryFromContext(ctx context.Context) *querySummary {
+	if summary, ok := ctx.Value(querySummaryKey{}).(*querySummary); ok {
+		return summary
+	}
+	return &querySummary{}
+}
+
+// recordSearch adds the search of a query to its summary
+func recordSearch(ctx context.Context, searchResponse *openobserve.SearchResponse, info *searchInfo) {
+	summary := querySummaryFromContext(ctx)
+	summary.searched = true
+	summary.cacheHit = info != nil && info.cacheHit
+	summary.hits, summary.total = len(searchResponse.Hits), searchResponse.Total
+	summary.took = time.Duration(searchResponse.Took) * time.Millisecond
+	summary.scanSize = searchResponse.ScanSize
+	summary.traceID = searchResponse.TraceID
+}
+
+// log writes the summary line of a query with its timings and sizes
+func (s *querySummary) log(ctx context.Context, resp backend.DataResponse, duration, queueWait time.Duration) {
+	rows := 0
+	for _, frame := range resp.Frames {
+		rows += frame.Rows()
+	}
+	args := []any{"duration", duration.String(), "queueWait", queueWait.String(), "frames", len(resp.Frames), "rows", rows, "transform", s.transform.String()}
+	if s.searched {
+		args = append(args, "openobserveTraceId", s.traceID, "cacheHit", s.cacheHit, "hits", s.hits, "total", s.total, "took", s.took.String(), "scanSizeMb", s.scanSize)
+	}
+	if resp.Error != nil {
+		args = append(args, "status", resp.Status, "error", resp.Error)
+	}
+	openobserve.Logger(ctx).Debug("Query completed", args...)
+}
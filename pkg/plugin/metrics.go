@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	transformDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: openobserve.MetricsNamespace,
+		Name:      "transform_duration_seconds",
+		Help:      "Time spent transforming OpenObserve search responses into data frames.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"query_type"})
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: openobserve.MetricsNamespace,
+		Name:      "cache_requests_total",
+		Help:      "Lookups of the result cache, the previous results of incremental queries and the stale results, by outcome.",
+	}, []string{"query_type", "cache", "result"})
+)
+
+// observeTransform records the time spent in the Transformer since start
+func observeTransform(ctx context.Context, start time.Time) {
+	transformDuration.WithLabelValues(openobserve.QueryTypeFromContext(ctx)).Observe(time.Since(start).Seconds())
+}
+
+// observeCache records a lookup of cache (result, incremental or stale)
+func observeCache(ctx context.Context, cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(openobserve.QueryTypeFromContext(ctx), cache, result).Inc()
+}
+
+// CollectMetrics returns the Prometheus metrics of the plugin in the text exposition format.
+// The metrics are registered with the default registry, which the SDK also serves to Grafana.
+func (ds *Datasource) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	metricFamilies, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, metricFamily := range metricFamilies {
+		if _, err := expfmt.MetricFamilyToText(&buf, metricFamily); err != nil {
+			return nil, err
+		}
+	}
+	return &backend.CollectMetricsResult{PrometheusMetrics: buf.Bytes()}, nil
+}
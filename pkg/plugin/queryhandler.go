@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental/concurrent"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // registerQueryHandlers registers the query handlers for different query types.
@@ -26,46 +29,86 @@ func (ds *Datasource) registerQueryHandlers() {
 
 // handleLogsQueryData handles log queries.
 func (ds *Datasource) handleLogsQueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	return concurrent.QueryData(ctx, req, ds.queryStream, 10)
+	return ds.queryData(ctx, req, "logs", ds.queryStream)
 }
 
 // handleMetricsQueryData handles metric queries.
 func (ds *Datasource) handleMetricsQueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	// return concurrent.QueryData(ctx, req, ds.queryMetrics, 10)
-	return concurrent.QueryData(ctx, req, ds.queryStream, 10)
+	return ds.queryData(ctx, req, "metrics", ds.queryStream)
 }
 
 // handleTracesQueryData handles trace queries.
 func (ds *Datasource) handleTracesQueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	return concurrent.QueryData(ctx, req, ds.queryStream, 10)
+	return ds.queryData(ctx, req, "traces", ds.queryStream)
 }
 
 // handleFallback handles fallback queries that do not match any specific type.
 func (ds *Datasource) handleFallback(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	return concurrent.QueryData(ctx, req, ds.queryFallback, 10)
+	return ds.queryData(ctx, req, fallbackQueryType, ds.queryFallback)
 }
 
 func (ds *Datasource) queryStream(ctx context.Context, query concurrent.Query) backend.DataResponse {
+	gqm, err := decodeQueryModel(query.DataQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("decodeQueryModel error: %v", err.Error()))
+	}
 
-	searchReqParam, searchReqBody, err := ds.prepareSearchRequest(query)
+	searchReqParam, searchReqBody, err := ds.prepareSearchRequest(ctx, query, gqm)
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("prepareSearchRequest errpr: %v", err.Error()))
 	}
-	searchResponse, err := ds.openObserveClient.Search(searchReqParam, searchReqBody)
-	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("openObserveClient.Search error: %v", err.Error()))
+
+	// stream the hits to the panel as they arrive or tail the stream, alerting cannot subscribe to Grafana Live
+	if !openobserve.IsAlerting(ctx) {
+		switch {
+		case gqm.LiveTail:
+			return ds.streamSearch(query, searchReqParam, searchReqBody, gqm.OutputFormat, true)
+		case gqm.Streaming && searchReqParam.EnableSSE:
+			return ds.streamSearch(query, searchReqParam, searchReqBody, gqm.OutputFormat, false)
+		}
 	}
 
-	parsedSql, err := ds.SqlParser.ParseSql(searchReqBody.Sql)
+	parsedSql, err := ds.parseSql(ctx, searchReqBody.Sql)
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("SqlParser.ParseSql error: %v", err.Error()))
 	}
 
+	search := ds.search
+	if gqm.Incremental && parsedSql.TimeBucketColumn != "" {
+		// only time series with fixed time buckets can be refreshed incrementally
+		search = ds.incrementalSearch
+	}
+	client := ds.openObserveClient.WithForwardedHeaders(query.Headers)
+	searchResponse, info, err := search(ctx, client, searchReqParam, searchReqBody, parsedSql, gqm)
+	var staleNotice *data.Notice
+	if err != nil {
+		stale, notice, ok := ds.staleResult(ctx, client, searchReqParam, searchReqBody, err)
+		if !ok {
+			return errDataResponse(err, "openObserveClient.Search error")
+		}
+		searchResponse, info, staleNotice = stale, &searchInfo{}, &notice
+	} else {
+		ds.rememberLastResult(client, searchReqParam, searchReqBody, searchResponse)
+	}
+	recordSearch(ctx, searchResponse, info)
+
 	// transform the OpenObserve response data into Grafana data frame
 	// doc: https://grafana.com/developers/plugin-tools/introduction/data-frames
-	frame, err := ds.transformer.TransformStream(parsedSql, searchResponse)
+	frame, err := transform(ctx, "Transform", func() (*data.Frame, error) {
+		return ds.transformer.Transform(parsedSql, searchResponse, gqm.OutputFormat)
+	})
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("transformer.TransformLogsStream error: %v", err.Error()))
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("transformer.Transform error: %v", err.Error()))
+	}
+	ds.transformer.SetSearchMeta(frame, searchReqBody.Sql, searchResponse)
+	if notice, ok := ds.truncationNotice(searchReqBody, parsedSql, searchResponse); ok {
+		frame.AppendNotices(notice)
+	}
+	if staleNotice != nil {
+		frame.AppendNotices(*staleNotice)
+	}
+	if ds.resultCache != nil {
+		frame.Meta.Stats = append(frame.Meta.Stats, ds.resultCache.stats(info.cacheHit)...)
 	}
 
 	frames := data.Frames{}
@@ -80,16 +123,21 @@ func (ds *Datasource) queryStream(ctx context.Context, query concurrent.Query) b
 // queryFallback is a fallback handler for queries that do not match any specific type
 // here we use it to handle queries emitted by the Grfana dynamic variables feature
 func (ds *Datasource) queryFallback(ctx context.Context, q concurrent.Query) backend.DataResponse {
-	searchReqParam, searchReqBody, err := ds.prepareSearchRequest(q)
+	gqm, err := decodeQueryModel(q.DataQuery)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("decodeQueryModel error: %v", err.Error()))
+	}
+	searchReqParam, searchReqBody, err := ds.prepareSearchRequest(ctx, q, gqm)
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("prepareSearchRequest error: %v", err.Error()))
 	}
+	client := ds.openObserveClient.WithForwardedHeaders(q.Headers)
 
 	// If the rawSql is "\\dt" (postgresql style), fetch databease tables(openobserve streams)
 	if strings.HasPrefix(searchReqBody.Sql, "\\dt") {
-		frame, err := ds.fallbackDisplayTables(searchReqParam.Organization, searchReqBody.Sql)
+		frame, err := ds.fallbackDisplayTables(ctx, client, searchReqParam.Organization, searchReqBody.Sql)
 		if err != nil {
-			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("fallbackDisplayTables error: %v", err.Error()))
+			return errDataResponse(err, "fallbackDisplayTables error")
 		}
 		frames := data.Frames{}
 		frames = append(frames, frame)
@@ -99,9 +147,9 @@ func (ds *Datasource) queryFallback(ctx context.Context, q concurrent.Query) bac
 		}
 	}
 
-	frame, err := ds.fallbackSelectFrom(searchReqParam, searchReqBody)
+	frame, err := ds.fallbackSelectFrom(ctx, client, searchReqParam, searchReqBody)
 	if err != nil {
-		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("fallbackSelectFrom error: %v", err.Error()))
+		return errDataResponse(err, "fallbackSelectFrom error")
 	}
 
 	frames := data.Frames{}
@@ -112,7 +160,7 @@ func (ds *Datasource) queryFallback(ctx context.Context, q concurrent.Query) bac
 	}
 }
 
-func (ds *Datasource) fallbackDisplayTables(organization string, rawSql string) (*data.Frame, error) {
+func (ds *Datasource) fallbackDisplayTables(ctx context.Context, client *openobserve.OpenObserveClient, organization string, rawSql string) (*data.Frame, error) {
 	parts := strings.Split(rawSql, " ")
 	if len(parts) != 2 || parts[0] != "\\dt" {
 		return nil, fmt.Errorf("invalid rawSql: %s, expected format: \\dt <stream_Type>", rawSql)
@@ -126,50 +174,64 @@ func (ds *Datasource) fallbackDisplayTables(organization string, rawSql string)
 		Organization: organization,
 		StreamType:   parts[1],
 	}
-	listStreamResp, err := ds.openObserveClient.ListStreams(listStreamParam)
+	listStreamResp, err := client.ListStreams(ctx, listStreamParam)
 	if err != nil {
-		return nil, fmt.Errorf("openObserveClient.ListStreams error: %v", err.Error())
+		return nil, fmt.Errorf("openObserveClient.ListStreams error: %w", err)
 	}
 
-	frame, err := ds.transformer.TransformFallbackDisplayTables(listStreamResp)
+	frame, err := transform(ctx, "TransformFallbackDisplayTables", func() (*data.Frame, error) {
+		return ds.transformer.TransformFallbackDisplayTables(listStreamResp)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("transformer.TransformFallbackDisplayTables error: %v", err.Error())
 	}
 	return frame, nil
 }
 
-func (ds *Datasource) fallbackSelectFrom(searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody) (*data.Frame, error) {
+func (ds *Datasource) fallbackSelectFrom(ctx context.Context, client *openobserve.OpenObserveClient, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody) (*data.Frame, error) {
 	// Perform the search request to OpenObserve
-	searchResponse, err := ds.openObserveClient.Search(searchReqParam, searchReqBody)
+	searchResponse, err := client.Search(ctx, searchReqParam, searchReqBody)
 	if err != nil {
-		return nil, fmt.Errorf("openObserveClient.Search error: %v", err.Error())
+		return nil, fmt.Errorf("openObserveClient.Search error: %w", err)
 	}
+	recordSearch(ctx, searchResponse, nil)
 
-	parsedSql, err := ds.SqlParser.ParseSql(searchReqBody.Sql)
+	parsedSql, err := ds.parseSql(ctx, searchReqBody.Sql)
 	if err != nil {
 		return nil, fmt.Errorf("SqlParser.ParseSql error: %v", err.Error())
 	}
 
 	// transform the OpenObserve response data into Grafana data frame
 	// doc: https://grafana.com/developers/plugin-tools/introduction/data-frames
-	frame, err := ds.transformer.TransformFallbackSelectFrom(parsedSql, searchResponse)
+	frame, err := transform(ctx, "TransformFallbackSelectFrom", func() (*data.Frame, error) {
+		return ds.transformer.TransformFallbackSelectFrom(parsedSql, searchResponse)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("transformer.TransformFallbackSelectFrom error: %v", err.Error())
 	}
+	ds.transformer.SetSearchMeta(frame, searchReqBody.Sql, searchResponse)
 
 	return frame, nil
 }
 
 type grafanaQueryModel struct {
 	// Organization string `json:"organization"`
-	QueryType    string                `json:"queryType"`  // logs, metrics, traces
-	SearchType   string                `json:"searchType"` // e.g., "ui", "api"
-	UseCache     bool                  `json:"useCache"`   // Whether to use cache or not
-	EnableSSE    bool                  `json:"enableSSE"`  // Whether to enable Server-Sent Events (SSE) for real-time data streaming
+	QueryType    string                `json:"queryType"`   // logs, metrics, traces
+	SearchType   string                `json:"searchType"`  // e.g., "ui", "api"
+	UseCache     bool                  `json:"useCache"`    // Whether to use cache or not
+	EnableSSE    bool                  `json:"enableSSE"`   // Whether to enable Server-Sent Events (SSE) for real-time data streaming
+	Streaming    bool                  `json:"streaming"`   // Whether to push partial SSE results to the panel through Grafana Live
+	LiveTail     bool                  `json:"liveTail"`    // Whether to tail the stream, publishing new rows through Grafana Live
+	Partitioned  *bool                 `json:"partitioned"` // Whether to split the search by time range, automatic if not set
+	Paginate     bool                  `json:"paginate"`    // Whether to fetch every matching row, up to the datasource row ceiling
+	BypassCache  bool                  `json:"bypassCache"` // Whether to skip the plugin result cache, the fresh result is still cached
+	Incremental  bool                  `json:"incremental"` // Whether to refresh time series by only searching the new part of the time range
 	RawSql       string                `json:"rawSql"`
 	From         int64                 `json:"from"`
 	Size         int64                 `json:"size"`
 	AdHocFilters []AdHocVariableFilter `json:"adhocFilters"` // Ad-hoc filters for the query
+	Timeout      int64                 `json:"timeout"`      // Query timeout in seconds, overrides the datasource default
+	OutputFormat string                `json:"outputFormat"` // Shape of the frame: auto, logs, table or time_series, see openobserve.Transformer.Transform
 }
 
 type AdHocVariableFilter struct {
@@ -182,20 +244,37 @@ type Organization struct {
 	Database string `json:"database"`
 }
 
-func (ds *Datasource) prepareSearchRequest(q concurrent.Query) (*openobserve.SearchRequestParam, *openobserve.SearchRequestBody, error) {
+// decodeQueryModel unmarshals and validates the model of a query
+func decodeQueryModel(query backend.DataQuery) (*grafanaQueryModel, error) {
+	var gqm grafanaQueryModel
+	if err := json.Unmarshal(query.JSON, &gqm); err != nil {
+		return nil, fmt.Errorf("json unmarshal query error: %v", err.Error())
+	}
+	if !openobserve.IsFormat(gqm.OutputFormat) {
+		return nil, fmt.Errorf("unsupported output format %q, expected one of: auto, logs, table, time_series", gqm.OutputFormat)
+	}
+	return &gqm, nil
+}
+
+// prepareSearchRequest builds the OpenObserve search of a query from its model, see decodeQueryModel
+func (ds *Datasource) prepareSearchRequest(ctx context.Context, q concurrent.Query, gqm *grafanaQueryModel) (_ *openobserve.SearchRequestParam, _ *openobserve.SearchRequestBody, err error) {
 	pCtx := q.PluginContext
 	query := q.DataQuery
-	log.DefaultLogger.Debug("prepareSearchRequest called", "query", query, "dataSourceInstanceSettings", pCtx.DataSourceInstanceSettings)
+	ctx, span := tracing.DefaultTracer().Start(ctx, "prepareSearchRequest", trace.WithAttributes(
+		attribute.String("query.ref_id", query.RefID),
+		attribute.String("query.type", query.QueryType),
+	))
+	defer func() {
+		if err != nil {
+			tracing.Error(span, err)
+		}
+		span.End()
+	}()
+	openobserve.Logger(ctx).Debug("prepareSearchRequest called", "query", query)
 	var organization Organization
 	if err := json.Unmarshal(pCtx.DataSourceInstanceSettings.JSONData, &organization); err != nil {
 		return nil, nil, err
 	}
-	// Unmarshal the JSON into our queryModel.
-	var gqm grafanaQueryModel
-
-	if err := json.Unmarshal(query.JSON, &gqm); err != nil {
-		return nil, nil, fmt.Errorf("json unmarshal query error: %v", err.Error())
-	}
 	filters := make([]openobserve.WhereFilter, 0, len(gqm.AdHocFilters))
 	for _, filter := range gqm.AdHocFilters {
 		filters = append(filters, openobserve.WhereFilter{
@@ -214,7 +293,7 @@ func (ds *Datasource) prepareSearchRequest(q concurrent.Query) (*openobserve.Sea
 			return nil, nil, fmt.Errorf("SqlParser.CompeleteSqlWithAdhocFilters error: %v", err.Error())
 		}
 		completedSql = sql
-		log.DefaultLogger.Debug("Completed SQL", "completedSql", completedSql)
+		openobserve.Logger(ctx).Debug("Completed SQL", "completedSql", completedSql)
 	}
 
 	// TODO: set the default values for
@@ -222,20 +301,19 @@ func (ds *Datasource) prepareSearchRequest(q concurrent.Query) (*openobserve.Sea
 	gqm.UseCache = true                       // Default to using cache
 
 	// Parse SQL to extract LIMIT value
-	parsedSql, err := ds.SqlParser.ParseSql(completedSql)
+	parsedSql, err := ds.parseSql(ctx, completedSql)
 	if err != nil {
-		log.DefaultLogger.Warn("prepareSearchRequest: Failed to parse SQL for LIMIT extraction", "error", err)
+		openobserve.Logger(ctx).Warn("prepareSearchRequest: Failed to parse SQL for LIMIT extraction", "error", err)
 		parsedSql = nil
 	}
 
 	// Determine the size to use:
 	// 1. If frontend explicitly set Size, use it (but cap at max)
 	// 2. If SQL has LIMIT clause, use that value (but cap at max)
-	// 3. Otherwise, use default of 200
-	const (
-		defaultSize int64 = 200
-		maxSize     int64 = 10000 // Maximum to prevent browser crashes and excessive memory usage
-	)
+	// 3. If the query asks for every row, use the max
+	// 4. Otherwise, use default of 200
+	// Sizes above a page are fetched by a paginated search, see Datasource.search
+	const defaultSize int64 = 200
 
 	size := defaultSize
 	if gqm.Size > 0 {
@@ -244,12 +322,24 @@ func (ds *Datasource) prepareSearchRequest(q concurrent.Query) (*openobserve.Sea
 	} else if parsedSql != nil && parsedSql.Limit > 0 {
 		// SQL has a LIMIT clause
 		size = parsedSql.Limit
+	} else if gqm.Paginate {
+		size = ds.maxRows
 	}
 
-	// Cap the size at maximum to prevent browser crashes
-	if size > maxSize {
-		log.DefaultLogger.Warn("prepareSearchRequest: Size exceeds maximum, capping", "requested", size, "max", maxSize)
-		size = maxSize
+	// Cap the size at the row ceiling to prevent browser crashes and excessive memory usage
+	if size > ds.maxRows {
+		openobserve.Logger(ctx).Warn("prepareSearchRequest: Size exceeds maximum, capping", "requested", size, "max", ds.maxRows)
+		size = ds.maxRows
+	}
+
+	// The per query timeout overrides the datasource default, but OpenObserve is never asked to
+	// search for longer than Grafana waits for the result
+	timeout := ds.queryTimeout
+	if gqm.Timeout > 0 {
+		timeout = time.Duration(gqm.Timeout) * time.Second
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline))
 	}
 
 	searchReqParam := &openobserve.SearchRequestParam{
@@ -260,16 +350,20 @@ func (ds *Datasource) prepareSearchRequest(q concurrent.Query) (*openobserve.Sea
 		EnableSSE:    gqm.EnableSSE,
 	}
 
+	span.SetAttributes(attribute.String("db.query.text", completedSql), attribute.Int64("openobserve.size", size))
 	searchReqBody := &openobserve.SearchRequestBody{
 		Query: openobserve.Query{
 			Sql:       completedSql,
 			StartTime: query.TimeRange.From.UnixMicro(),
 			EndTime:   query.TimeRange.To.UnixMicro(),
 			From:      gqm.From,
-			Size:      size, // Use parsed LIMIT or default, capped at maxSize
+			Size:      size, // Use parsed LIMIT or default, capped at the row ceiling
+			// the truncation notice compares the rows with the total, which OpenObserve only counts
+			// on request, unless the rows are cut by the LIMIT of the SQL, see Datasource.truncationNotice
+			TrackTotalHits: parsedSql == nil || parsedSql.Limit <= 0 || size < parsedSql.Limit,
 		},
 		SearchType: openobserve.SearchTypeUI,
-		Timeout:    60, // default to 60 seconds timeout
+		Timeout:    max(int(timeout/time.Second), 1),
 	}
 
 	return searchReqParam, searchReqBody, nil
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// searchInfo describes how the result of a search was obtained
+type searchInfo struct {
+	cacheHit bool // the result was served from the plugin result cache
+}
+
+// search runs the search of a query, served from the result cache when an identical search ran
+// recently unless the query bypasses the cache. The search is split into time range partitions
+// when the query asks for it or, unless the query opts out, when its time range exceeds the
+// configured threshold. Searches returning more hits than a request can carry are paginated.
+func (ds *Datasource) search(ctx context.Context, client *openobserve.OpenObserveClient, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody, parsedSql *openobserve.SQL, gqm *grafanaQueryModel) (*openobserve.SearchResponse, *searchInfo, error) {
+	if ds.resultCache == nil {
+		searchResponse, err := ds.searchUncached(ctx, client, searchReqParam, searchReqBody, parsedSql, gqm.Partitioned)
+		return searchResponse, &searchInfo{}, err
+	}
+
+	key := cacheKey(client.Identity(), searchReqParam, searchReqBody)
+	if !gqm.BypassCache {
+		cached, ok := ds.resultCache.get(key)
+		observeCache(ctx, "result", ok)
+		if ok {
+			return cached.searchResponse, &searchInfo{cacheHit: true}, nil
+		}
+	}
+	searchResponse, err := ds.searchUncached(ctx, client, searchReqParam, searchReqBody, parsedSql, gqm.Partitioned)
+	if err != nil {
+		return nil, nil, err
+	}
+	ds.resultCache.set(key, &cachedSearch{searchResponse: searchResponse, startTime: searchReqBody.StartTime, endTime: searchReqBody.EndTime})
+	return searchResponse, &searchInfo{}, nil
+}
+
+// searchUncached runs the search of a query against OpenObserve
+func (ds *Datasource) searchUncached(ctx context.Context, client *openobserve.OpenObserveClient, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody, parsedSql *openobserve.SQL, partitioned *bool) (*openobserve.SearchResponse, error) {
+	if ds.shouldPartition(searchReqBody, parsedSql, partitioned) {
+		return client.PartitionedSearch(ctx, searchReqParam, searchReqBody, openobserve.PartitionOptions{
+			Concurrency: ds.partitionConcurrency,
+			OrderBy:     parsedSql.OrderBy,
+			PageSize:    openobserve.DefaultPageSize,
+			Aggregates:  parsedSql.Aggregates,
+		})
+	}
+	return client.PaginatedSearch(ctx, searchReqParam, searchReqBody, openobserve.DefaultPageSize)
+}
+
+// truncationNotice tells the user that a search returned fewer rows than OpenObserve reports in
+// total, unless the rows were cut by the LIMIT of the SQL
+func (ds *Datasource) truncationNotice(searchReqBody *openobserve.SearchRequestBody, parsedSql *openobserve.SQL, searchResponse *openobserve.SearchResponse) (data.Notice, bool) {
+	rows, total := int64(len(searchResponse.Hits)), int64(searchResponse.Total)
+	if total <= searchReqBody.From+rows || (parsedSql.Limit > 0 && rows >= parsedSql.Limit) {
+		return data.Notice{}, false
+	}
+
+	text := fmt.Sprintf("Results truncated: showing %d of %d rows, add a LIMIT or enable pagination to get more", rows, total)
+	if searchReqBody.Size >= ds.maxRows {
+		text = fmt.Sprintf("Results truncated: showing %d of %d rows, the datasource returns at most %d rows per query", rows, total, ds.maxRows)
+	}
+	return data.Notice{Severity: data.NoticeSeverityWarning, Text: text}, true
+}
+
+// shouldPartition reports whether a search is split into time range partitions. Searches whose
+// results over consecutive time ranges cannot be merged, e.g. GROUP BY other than time buckets,
+// are never partitioned.
+func (ds *Datasource) shouldPartition(searchReqBody *openobserve.SearchRequestBody, parsedSql *openobserve.SQL, partitioned *bool) bool {
+	if !parsedSql.Partitionable() {
+		return false
+	}
+	if partitioned != nil {
+		return *partitioned
+	}
+	timeRange := time.Duration(searchReqBody.EndTime-searchReqBody.StartTime) * time.Microsecond
+	return timeRange > ds.partitionMinRange
+}
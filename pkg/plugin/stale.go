@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// staleResultExpiry bounds how old a result served while OpenObserve is unavailable may be
+const staleResultExpiry = time.Hour
+
+// rememberLastResult keeps the result of a query, whatever its time range, to be served while
+// OpenObserve is unavailable
+func (ds *Datasource) rememberLastResult(client *openobserve.OpenObserveClient, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody, searchResponse *openobserve.SearchResponse) {
+	if ds.lastResults == nil {
+		return
+	}
+	ds.lastResults.set(incrementalKey(client.Identity(), searchReqParam, searchReqBody), &cachedSearch{
+		searchResponse: searchResponse,
+		startTime:      searchReqBody.StartTime,
+		endTime:        searchReqBody.EndTime,
+	})
+}
+
+// staleResult returns the last result of a query along with a notice telling it is stale, when
+// the search failed because the circuit breaker suspended the requests to OpenObserve
+func (ds *Datasource) staleResult(ctx context.Context, client *openobserve.OpenObserveClient, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody, err error) (*openobserve.SearchResponse, data.Notice, bool) {
+	if ds.lastResults == nil || !errors.Is(err, openobserve.ErrCircuitOpen) {
+		return nil, data.Notice{}, false
+	}
+	last, ok := ds.lastResults.get(incrementalKey(client.Identity(), searchReqParam, searchReqBody))
+	observeCache(ctx, "stale", ok)
+	if !ok {
+		return nil, data.Notice{}, false
+	}
+	return last.searchResponse, data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Stale data: %v. Showing the last result, up to %s", err, time.UnixMicro(last.endTime).UTC().Format(time.RFC3339)),
+	}, true
+}
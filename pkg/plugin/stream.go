@@ -0,0 +1,270 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/concurrent"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+const (
+	// searchStreamPathPrefix prefixes the Grafana Live channel path of a streamed search
+	searchStreamPathPrefix = "search/"
+	// tailStreamPathPrefix prefixes the Grafana Live channel path of a live tail
+	tailStreamPathPrefix = "tail/"
+	// searchStreamExpiry bounds how long a streamed search is kept while nobody is subscribed to it,
+	// e.g. before the first subscriber or between a Live reconnect and the resubscription
+	searchStreamExpiry = time.Minute
+)
+
+// searchStream is a search registered by QueryData and run by RunStream whenever a panel subscribes
+// to its channel
+type searchStream struct {
+	refID          string
+	headers        http.Header // forwarded identity of the Grafana user
+	searchReqParam *openobserve.SearchRequestParam
+	searchReqBody  *openobserve.SearchRequestBody
+	liveTail       bool   // repeatedly run the search over new rows instead of once
+	format         string // shape of the frames of a search run once, live tails always send logs
+
+	running  int       // runs of the search in progress, guarded by searchStreams.mu
+	lastUsed time.Time // when the search was registered or last run, guarded by searchStreams.mu
+}
+
+// searchStreams holds the streamed searches, by channel path. The searches nobody is subscribed to
+// anymore are dropped on lookup and periodically, until close is called, so that the forwarded
+// headers they hold do not stay in memory.
+type searchStreams struct {
+	mu       sync.Mutex
+	searches map[string]*searchStream
+	stop     chan struct{}
+}
+
+func newSearchStreams() *searchStreams {
+	s := &searchStreams{searches: make(map[string]*searchStream), stop: make(chan struct{})}
+	go s.expire()
+	return s
+}
+
+// expire drops the expired searches every searchStreamExpiry until close is called
+func (s *searchStreams) expire() {
+	ticker := time.NewTicker(searchStreamExpiry)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		s.sweep()
+		s.mu.Unlock()
+	}
+}
+
+// close stops dropping the expired searches periodically
+func (s *searchStreams) close() {
+	close(s.stop)
+}
+
+// sweep drops the searches nobody is subscribed to anymore, e.g. because the panel was closed,
+// s.mu must be held
+func (s *searchStreams) sweep() {
+	for path, search := range s.searches {
+		if search.expired() {
+			delete(s.searches, path)
+		}
+	}
+}
+
+// lookup returns the search streamed on path unless it expired, s.mu must be held
+func (s *searchStreams) lookup(path string) (*searchStream, bool) {
+	search, ok := s.searches[path]
+	if ok && search.expired() {
+		delete(s.searches, path)
+		return nil, false
+	}
+	return search, ok
+}
+
+// add registers search and returns the channel path it is streamed on
+func (s *searchStreams) add(search *searchStream) string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	path := searchStreamPathPrefix + hex.EncodeToString(id)
+	if search.liveTail {
+		path = tailStreamPathPrefix + hex.EncodeToString(id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	search.lastUsed = time.Now()
+	s.searches[path] = search
+	return path
+}
+
+// expired reports whether nobody subscribed to search for searchStreamExpiry, s.mu must be held
+func (search *searchStream) expired() bool {
+	return search.running == 0 && time.Since(search.lastUsed) > searchStreamExpiry
+}
+
+// get returns the search streamed on path
+func (s *searchStreams) get(path string) (*searchStream, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookup(path)
+}
+
+// run returns the search streamed on path, which is kept while it runs, and the function to call
+// once the run ends. A search may be run again, e.g. when a panel resubscribes after a Live reconnect.
+func (s *searchStreams) run(path string) (*searchStream, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	search, ok := s.lookup(path)
+	if !ok {
+		return nil, nil, false
+	}
+	search.running++
+	return search, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		search.running--
+		search.lastUsed = time.Now()
+	}, true
+}
+
+// streamProgress is reported in the custom meta of the frames of a streamed search
+type streamProgress struct {
+	Percent int  `json:"percent"` // completion percentage reported by OpenObserve
+	Hits    int  `json:"hits"`    // number of hits streamed so far
+	Done    bool `json:"done"`    // whether the search completed
+}
+
+// streamSearch registers the search of query to be streamed, or tailed if liveTail is set, through
+// Grafana Live, and returns the response pointing the panel to its channel. The results are sent by RunStream
+// in the given format.
+func (ds *Datasource) streamSearch(query concurrent.Query, searchReqParam *openobserve.SearchRequestParam, searchReqBody *openobserve.SearchRequestBody, format string, liveTail bool) backend.DataResponse {
+	path := ds.searchStreams.add(&searchStream{
+		refID:          query.DataQuery.RefID,
+		headers:        query.Headers,
+		searchReqParam: searchReqParam,
+		searchReqBody:  searchReqBody,
+		liveTail:       liveTail,
+		format:         format,
+	})
+	channel := live.Channel{
+		Scope:     live.ScopeDatasource,
+		Namespace: query.PluginContext.DataSourceInstanceSettings.UID,
+		Path:      path,
+	}
+
+	frame := data.NewFrame(query.DataQuery.RefID)
+	frame.SetMeta(&data.FrameMeta{Channel: channel.String()})
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// SubscribeStream is called when a panel subscribes to the channel of a streamed search
+func (ds *Datasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if _, ok := ds.searchStreams.get(req.Path); !ok {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream is called when a client publishes to a channel, which is not supported
+func (ds *Datasource) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream runs a streamed search and sends a frame for every chunk of hits returned by
+// _search_stream, carrying the progress of the search in its custom meta. The stream ends when
+// OpenObserve signals the completion of the search, errors are reported as a frame notice since
+// Grafana restarts streams which return an error. Live tails are run by runLiveTail.
+func (ds *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	search, done, ok := ds.searchStreams.run(req.Path)
+	if !ok {
+		return fmt.Errorf("unknown search stream: %s", req.Path)
+	}
+	defer done()
+	ctx = openobserve.WithQueryType(ctx, search.searchReqParam.StreamType)
+	ctx = openobserve.WithLogAttributes(ctx, "refId", search.refID, "path", req.Path)
+	if search.liveTail {
+		return ds.runLiveTail(ctx, search, sender)
+	}
+
+	parsedSql, err := ds.parseSql(ctx, search.searchReqBody.Sql)
+	if err != nil {
+		return sendStreamError(sender, search.refID, fmt.Errorf("SqlParser.ParseSql error: %w", err))
+	}
+
+	client := ds.openObserveClient.WithForwardedHeaders(search.headers)
+	progress := streamProgress{}
+	var lastFrame *data.Frame
+	_, err = client.SearchStream(ctx, search.searchReqParam, search.searchReqBody, func(event *openobserve.SearchStreamEvent) error {
+		progress.Percent = event.Progress
+		if len(event.Hits) == 0 {
+			// progress only, nothing to report before the shape of the frame is known
+			if lastFrame == nil {
+				return nil
+			}
+			return sendStreamFrame(sender, lastFrame.EmptyCopy(), progress)
+		}
+
+		transformStart := time.Now()
+		frame, err := ds.transformer.Transform(parsedSql, &openobserve.SearchResponse{Hits: event.Hits}, search.format)
+		observeTransform(ctx, transformStart)
+		if err != nil {
+			return fmt.Errorf("transformer.Transform error: %w", err)
+		}
+		frame.Name = search.refID
+		progress.Hits += len(event.Hits)
+		lastFrame = frame
+		return sendStreamFrame(sender, frame, progress)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// every subscriber left, the search was cancelled on the OpenObserve side
+			return nil
+		}
+		return sendStreamError(sender, search.refID, err)
+	}
+
+	openobserve.Logger(ctx).Debug("Search stream completed", "hits", progress.Hits)
+	progress.Percent, progress.Done = 100, true
+	frame := data.NewFrame(search.refID)
+	if lastFrame != nil {
+		frame = lastFrame.EmptyCopy()
+	}
+	return sendStreamFrame(sender, frame, progress)
+}
+
+// sendStreamFrame sends frame with progress in its custom meta
+func sendStreamFrame(sender *backend.StreamSender, frame *data.Frame, progress streamProgress) error {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	frame.Meta.Custom = progress
+	return sender.SendFrame(frame, data.IncludeAll)
+}
+
+// sendStreamError reports the failure of a streamed search as an error notice
+func sendStreamError(sender *backend.StreamSender, refID string, err error) error {
+	log.DefaultLogger.Warn("Search stream failed", "refID", refID, "error", err)
+	frame := data.NewFrame(refID)
+	frame.SetMeta(&data.FrameMeta{
+		Notices: []data.Notice{{Severity: data.NoticeSeverityError, Text: fmt.Sprintf("openObserveClient.SearchStream error: %v", err)}},
+		Custom:  streamProgress{Done: true},
+	})
+	return sender.SendFrame(frame, data.IncludeAll)
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/bytedance/sonic/encoder"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// tailCursor is the position of a live tail: the latest _timestamp published and the rows published
+// at that timestamp. Each poll searches the window starting at the cursor timestamp, so that rows
+// ingested with the same timestamp after the previous poll are not missed, and drops the rows already
+// published. A window holding more rows than a poll may publish is paged across polls, its end is
+// kept until its last page so that the offsets of the pages stay valid.
+type tailCursor struct {
+	timestamp int64               // microseconds
+	seen      map[string]struct{} // rows at timestamp already published, encoded as JSON
+
+	end        int64               // end of the window being paged, 0 before its first page
+	from       int64               // offset of the next page of the window
+	latest     int64               // latest _timestamp published from the window
+	latestSeen map[string]struct{} // rows at latest published from the window
+}
+
+func newTailCursor(start time.Time) *tailCursor {
+	return &tailCursor{timestamp: start.UnixMicro(), seen: make(map[string]struct{})}
+}
+
+// window returns the time range and the offset of the next page to search
+func (c *tailCursor) window(now time.Time) (start, end, from int64) {
+	if c.end == 0 {
+		c.end, c.from = now.UnixMicro(), 0
+		c.latest, c.latestSeen = c.timestamp, make(map[string]struct{})
+	}
+	return c.timestamp, c.end, c.from
+}
+
+// advance returns the hits of a page of the window which were not published yet. Once the last page
+// of the window, shorter than size, is returned, the cursor moves past the rows of the window.
+func (c *tailCursor) advance(hits []map[string]any, size int64) ([]map[string]any, error) {
+	newHits := make([]map[string]any, 0, len(hits))
+	for _, hit := range hits {
+		timestamp, ok := hitTimestamp(hit)
+		if !ok {
+			return nil, fmt.Errorf("live tail requires the _timestamp column, select it along with the other columns")
+		}
+		if timestamp < c.timestamp {
+			continue
+		}
+		key, err := encoder.Encode(hit, encoder.SortMapKeys)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := c.seen[string(key)]; ok && timestamp == c.timestamp {
+			continue
+		}
+		newHits = append(newHits, hit)
+		if timestamp > c.latest {
+			c.latest = timestamp
+			clear(c.latestSeen)
+		}
+		if timestamp == c.latest {
+			c.latestSeen[string(key)] = struct{}{}
+		}
+	}
+
+	if int64(len(hits)) >= size {
+		c.from += int64(len(hits))
+		return newHits, nil
+	}
+	if c.latest > c.timestamp {
+		c.timestamp, c.seen = c.latest, c.latestSeen
+	} else {
+		maps.Copy(c.seen, c.latestSeen)
+	}
+	c.end = 0
+	return newHits, nil
+}
+
+// paging reports whether the cursor is in the middle of a window which did not fit in a poll
+func (c *tailCursor) paging() bool {
+	return c.end != 0
+}
+
+// hitTimestamp returns the _timestamp of a hit in microseconds
+func hitTimestamp(hit map[string]any) (int64, bool) {
+	timestamp, ok := hit["_timestamp"].(float64)
+	return int64(timestamp), ok
+}
+
+// runLiveTail repeatedly runs the search over the window starting at the last published _timestamp
+// and publishes the new rows as log frames, until every subscriber left. A poll publishes at most the
+// rows allowed by the rate limit over the poll interval, the rest of a busier window is published by
+// the next polls.
+func (ds *Datasource) runLiveTail(ctx context.Context, search *searchStream, sender *backend.StreamSender) error {
+	client := ds.openObserveClient.WithForwardedHeaders(search.headers)
+	maxRows := max(int64(float64(ds.liveTailRateLimit)*ds.liveTailInterval.Seconds()), 1)
+	cursor := newTailCursor(time.Now())
+
+	ticker := time.NewTicker(ds.liveTailInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			openobserve.Logger(ctx).Debug("Live tail stopped")
+			return nil
+		case <-ticker.C:
+		}
+
+		searchReqBody := *search.searchReqBody
+		searchReqBody.StartTime, searchReqBody.EndTime, searchReqBody.From = cursor.window(time.Now())
+		searchReqBody.Size = maxRows
+		searchResponse, err := client.Search(ctx, search.searchReqParam, &searchReqBody)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			// keep tailing, the next poll searches the same page again
+			if err := ds.sendTailWarning(sender, search.refID, fmt.Sprintf("openObserveClient.Search error: %v", err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		hits, err := cursor.advance(searchResponse.Hits, maxRows)
+		if err != nil {
+			return sendStreamError(sender, search.refID, err)
+		}
+		if len(hits) == 0 {
+			continue
+		}
+		transformStart := time.Now()
+		frame, err := ds.transformer.TransformLogs(&openobserve.SearchResponse{Hits: hits})
+		observeTransform(ctx, transformStart)
+		if err != nil {
+			return sendStreamError(sender, search.refID, fmt.Errorf("transformer.TransformLogs error: %w", err))
+		}
+		frame.Name = search.refID
+		if cursor.paging() {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityInfo,
+				Text:     fmt.Sprintf("Live tail is limited to %d rows per second, the rows are published with a delay", ds.liveTailRateLimit),
+			})
+		}
+		if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
+			return err
+		}
+	}
+}
+
+// sendTailWarning sends an empty log frame carrying a warning
+func (ds *Datasource) sendTailWarning(sender *backend.StreamSender, refID string, text string) error {
+	frame, err := ds.transformer.TransformLogs(&openobserve.SearchResponse{})
+	if err != nil {
+		return err
+	}
+	frame.Name = refID
+	frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: text})
+	return sender.SendFrame(frame, data.IncludeAll)
+}
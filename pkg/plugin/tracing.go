@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// parseSql parses sql within a span of the query trace
+func (ds *Datasource) parseSql(ctx context.Context, sql string) (*openobserve.SQL, error) {
+	_, span := tracing.DefaultTracer().Start(ctx, "SqlParser.ParseSql", trace.WithAttributes(attribute.String("db.query.text", sql)))
+	defer span.End()
+
+	parsedSql, err := ds.SqlParser.ParseSql(sql)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	span.SetAttributes(
+		attribute.Bool("sql.aggregated", parsedSql.Aggregated),
+		attribute.Bool("sql.time_bucketed", parsedSql.TimeBucketed),
+		attribute.Int64("sql.limit", parsedSql.Limit),
+	)
+	return parsedSql, nil
+}
+
+// transform runs the Transformer method name within a span of the query trace and records the
+// time spent for the transform metrics
+func transform(ctx context.Context, name string, fn func() (*data.Frame, error)) (*data.Frame, error) {
+	_, span := tracing.DefaultTracer().Start(ctx, "Transformer."+name)
+	defer span.End()
+
+	start := time.Now()
+	frame, err := fn()
+	observeTransform(ctx, start)
+	querySummaryFromContext(ctx).transform += time.Since(start)
+	if err != nil {
+		return nil, tracing.Error(span, err)
+	}
+	span.SetAttributes(attribute.Int("frame.rows", frame.Rows()), attribute.Int("frame.fields", len(frame.Fields)))
+	return frame, nil
+}
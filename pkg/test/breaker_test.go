@@ -0,0 +1,91 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_CircuitBreaker(t *testing.T) {
+	var (
+		healthy  atomic.Bool
+		searches atomic.Int64
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		searches.Add(1)
+		if !healthy.Load() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.Write([]byte(`{"hits":[{"_timestamp":1,"log":"a"}],"total":1}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{
+		"circuitBreakerFailures":     2,
+		"circuitBreakerOpenDuration": 1,
+		"serveStaleResults":          true,
+		"disableCache":               true,
+	})
+	query := func(rawSql string) backend.DataResponse {
+		t.Helper()
+		resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": rawSql}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+
+	healthy.Store(true)
+	if res := query("select * from log_stream"); res.Error != nil {
+		t.Fatalf("healthy query error: %v", res.Error)
+	}
+
+	// consecutive failures open the breaker
+	healthy.Store(false)
+	for range 2 {
+		if res := query("select * from audit"); res.Status != backend.StatusBadGateway {
+			t.Fatalf("failing query status = %v, want %v", res.Status, backend.StatusBadGateway)
+		}
+	}
+	before := searches.Load()
+	res := query("select * from audit")
+	if res.Error == nil || !strings.Contains(res.Error.Error(), "requests are suspended") {
+		t.Fatalf("query error = %v, want a suspended requests error", res.Error)
+	}
+	if searches.Load() != before {
+		t.Error("the open breaker sent a request to OpenObserve")
+	}
+
+	// the last result of a query is served while the breaker is open
+	res = query("select * from log_stream")
+	if res.Error != nil {
+		t.Fatalf("stale query error: %v", res.Error)
+	}
+	frame := res.Frames[0]
+	if rows := frame.Rows(); rows != 1 {
+		t.Errorf("stale rows = %d, want 1", rows)
+	}
+	if frame.Meta == nil || !slices.ContainsFunc(frame.Meta.Notices, func(notice data.Notice) bool {
+		return notice.Severity == data.NoticeSeverityWarning && strings.HasPrefix(notice.Text, "Stale data")
+	}) {
+		t.Errorf("stale frame notices = %+v, want a stale data notice", frame.Meta)
+	}
+
+	// a successful probe closes the breaker once it was open long enough
+	healthy.Store(true)
+	time.Sleep(1100 * time.Millisecond)
+	for range 2 {
+		if res := query("select * from audit"); res.Error != nil {
+			t.Fatalf("query error after recovery: %v", res.Error)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_ResultCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		jsonData  map[string]any
+		queries   []map[string]any
+		wait      time.Duration // between the queries
+		wantCalls int64
+		wantHit   float64 // "Cache hit" stat of the last query
+	}{
+		{
+			name:      "repeated query is served from the cache",
+			queries:   []map[string]any{{"rawSql": "select * from log_stream"}, {"rawSql": "select * from log_stream"}},
+			wantCalls: 1,
+			wantHit:   1,
+		},
+		{
+			name:      "different queries are not shared",
+			queries:   []map[string]any{{"rawSql": "select * from log_stream"}, {"rawSql": "select * from log_stream where level = 'error'"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "bypass skips the cache",
+			queries:   []map[string]any{{"rawSql": "select * from log_stream"}, {"rawSql": "select * from log_stream", "bypassCache": true}},
+			wantCalls: 2,
+		},
+		{
+			name:      "expired results are searched again",
+			jsonData:  map[string]any{"cacheTTL": 1},
+			queries:   []map[string]any{{"rawSql": "select * from log_stream"}, {"rawSql": "select * from log_stream"}},
+			wait:      1100 * time.Millisecond,
+			wantCalls: 2,
+		},
+		{
+			name:     "least recently used results are evicted beyond the size bound",
+			jsonData: map[string]any{"cacheMaxSize": 1},
+			queries: []map[string]any{
+				{"rawSql": "select * from log_stream", "size": 4000},
+				{"rawSql": "select * from log_stream limit 3999", "size": 3999},
+				{"rawSql": "select * from log_stream", "size": 4000},
+			},
+			wantCalls: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				calls.Add(1)
+				var body struct {
+					Query struct {
+						Size int `json:"size"`
+					} `json:"query"`
+				}
+				json.NewDecoder(req.Body).Decode(&body)
+				hits := make([]map[string]any, 0, body.Query.Size)
+				for n := range body.Query.Size {
+					hits = append(hits, map[string]any{"_timestamp": n, "log": "some log line"})
+				}
+				json.NewEncoder(rw).Encode(map[string]any{"hits": hits, "total": len(hits)})
+			}))
+			defer srv.Close()
+
+			ds, settings := newTestDatasource(t, srv.URL, tt.jsonData)
+			to := time.Now()
+			var frame *data.Frame
+			for i, query := range tt.queries {
+				if i > 0 {
+					time.Sleep(tt.wait)
+				}
+				query["queryType"] = "logs"
+				req := newQueryDataRequest(settings, query)
+				req.Queries[0].TimeRange.From, req.Queries[0].TimeRange.To = to.Add(-time.Hour), to
+				resp, err := ds.QueryData(context.Background(), req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := resp.Responses["A"].Error; err != nil {
+					t.Fatalf("query %d error: %v", i, err)
+				}
+				frame = resp.Responses["A"].Frames[0]
+			}
+
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("searches = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+			if got := cacheStat(frame, "Cache hit"); got != tt.wantHit {
+				t.Errorf("Cache hit = %v, want %v", got, tt.wantHit)
+			}
+		})
+	}
+}
+
+// cacheStat returns the value of the frame meta stat named name, -1 if missing
+func cacheStat(frame *data.Frame, name string) float64 {
+	if frame.Meta == nil {
+		return -1
+	}
+	for _, stat := range frame.Meta.Stats {
+		if stat.DisplayName == name {
+			return stat.Value
+		}
+	}
+	return -1
+}
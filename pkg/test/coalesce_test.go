@@ -0,0 +1,259 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_CoalescesIdenticalSearches(t *testing.T) {
+	tests := []struct {
+		name      string
+		jsonData  map[string]any
+		headers   func(i int) map[string]string
+		wantCalls int64
+	}{
+		{
+			name:      "identical searches share a request",
+			headers:   func(i int) map[string]string { return nil },
+			wantCalls: 1,
+		},
+		{
+			name:      "same forwarded identity shares a request",
+			jsonData:  map[string]any{"oauthPassThru": true},
+			headers:   func(i int) map[string]string { return map[string]string{"Authorization": "Bearer user-token"} },
+			wantCalls: 1,
+		},
+		{
+			name:     "different forwarded identities do not share a request",
+			jsonData: map[string]any{"oauthPassThru": true},
+			headers: func(i int) map[string]string {
+				return map[string]string{"Authorization": fmt.Sprintf("Bearer user-token-%d", i%2)}
+			},
+			wantCalls: 2,
+		},
+		{
+			name:      "same forwarded cookie shares a request",
+			jsonData:  map[string]any{"keepCookies": []string{"session"}},
+			headers:   func(i int) map[string]string { return map[string]string{"Cookie": "session=abc"} },
+			wantCalls: 1,
+		},
+		{
+			name:     "different forwarded cookies do not share a request",
+			jsonData: map[string]any{"keepCookies": []string{"session"}},
+			headers: func(i int) map[string]string {
+				return map[string]string{"Cookie": fmt.Sprintf("session=%d", i%2)}
+			},
+			wantCalls: 2,
+		},
+		{
+			name:     "different forwarded ID tokens do not share a request",
+			jsonData: map[string]any{"oauthPassThru": true},
+			headers: func(i int) map[string]string {
+				return map[string]string{"Authorization": "Bearer user-token", "X-Id-Token": fmt.Sprintf("id-token-%d", i%2)}
+			},
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const callers = 5
+			var calls atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				calls.Add(1)
+				time.Sleep(200 * time.Millisecond) // let every caller join
+				rw.Write([]byte(`{"hits":[{"_timestamp":1,"log":"a"},{"_timestamp":2,"log":"b"}],"total":2}`))
+			}))
+			defer srv.Close()
+
+			if tt.jsonData == nil {
+				tt.jsonData = map[string]any{}
+			}
+			tt.jsonData["disableCache"] = true
+			ds, settings := newTestDatasource(t, srv.URL, tt.jsonData)
+			to := time.Now()
+
+			var wg sync.WaitGroup
+			for i := range callers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": "select * from log_stream"})
+					req.Queries[0].TimeRange.From, req.Queries[0].TimeRange.To = to.Add(-time.Hour), to
+					req.Headers = tt.headers(i)
+					resp, err := ds.QueryData(context.Background(), req)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					res := resp.Responses["A"]
+					if res.Error != nil {
+						t.Errorf("caller %d error: %v", i, res.Error)
+						return
+					}
+					if rows, _ := res.Frames[0].RowLen(); rows != 2 {
+						t.Errorf("caller %d rows = %d, want 2", i, rows)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("searches = %d, want %d", calls.Load(), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestQueryData_CoalescedSearchOutlivesCancelledCaller(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/default/_search" {
+			return // cancellation of the search
+		}
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		rw.Write([]byte(`{"hits":[{"_timestamp":1,"log":"a"}],"total":1}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"disableCache": true})
+	to := time.Now()
+	query := func(ctx context.Context) error {
+		req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": "select * from log_stream"})
+		req.Queries[0].TimeRange.From, req.Queries[0].TimeRange.To = to.Add(-time.Hour), to
+		resp, err := ds.QueryData(ctx, req)
+		if err != nil {
+			return err
+		}
+		return resp.Responses["A"].Error
+	}
+
+	// the first caller gives up while the second one still waits for the shared search
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- query(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	if err := query(context.Background()); err != nil {
+		t.Fatalf("waiting caller error: %v", err)
+	}
+	if err := <-errs; err == nil {
+		t.Error("cancelled caller got no error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("searches = %d, want 1", calls.Load())
+	}
+}
+
+// limitedCoalescer runs the logs queries of its callers through a datasource sending a single
+// request at a time to a server which records the streams in the order it searches them
+type limitedCoalescer struct {
+	t        *testing.T
+	ds       *plugin.Datasource
+	settings backend.DataSourceInstanceSettings
+	to       time.Time
+
+	mu    sync.Mutex
+	order []string
+	wg    sync.WaitGroup
+}
+
+func newLimitedCoalescer(t *testing.T) *limitedCoalescer {
+	c := &limitedCoalescer{t: t, to: time.Now()}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		c.mu.Lock()
+		c.order = append(c.order, strings.TrimPrefix(body.Sql, "select * from "))
+		c.mu.Unlock()
+		time.Sleep(200 * time.Millisecond)
+		rw.Write([]byte(`{"hits":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+	c.ds, c.settings = newTestDatasource(t, srv.URL, map[string]any{"maxConcurrentQueries": 1, "disableCache": true})
+	return c
+}
+
+// run queries stream in the background, the returned channel receives the response
+func (c *limitedCoalescer) run(stream string, headers map[string]string) <-chan backend.DataResponse {
+	responses := make(chan backend.DataResponse, 1)
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		req := newQueryDataRequest(c.settings, map[string]any{"queryType": "logs", "rawSql": "select * from " + stream})
+		req.Queries[0].TimeRange.From, req.Queries[0].TimeRange.To = c.to.Add(-time.Hour), c.to
+		req.Headers = headers
+		resp, err := c.ds.QueryData(context.Background(), req)
+		if err != nil {
+			c.t.Error(err)
+			return
+		}
+		responses <- resp.Responses["A"]
+	}()
+	time.Sleep(30 * time.Millisecond) // let the query queue up before the next one
+	return responses
+}
+
+func (c *limitedCoalescer) searchOrder() string {
+	c.wg.Wait()
+	return strings.Join(c.order, ",")
+}
+
+func queueWaitStat(frame *data.Frame) (float64, bool) {
+	for _, stat := range frame.Meta.Stats {
+		if stat.DisplayName == "Queue wait time" {
+			return stat.Value, true
+		}
+	}
+	return 0, false
+}
+
+func TestQueryData_CoalescedSearchQueueWait(t *testing.T) {
+	c := newLimitedCoalescer(t)
+	c.run("running", nil)
+	first := c.run("shared", nil)
+	joiner := c.run("shared", nil)
+	if got := c.searchOrder(); got != "running,shared" {
+		t.Fatalf("search order = %s, want running,shared", got)
+	}
+
+	// the joiner waited for the limiter as long as the shared search it joined
+	for name, responses := range map[string]<-chan backend.DataResponse{"first caller": first, "joiner": joiner} {
+		res := <-responses
+		if res.Error != nil {
+			t.Fatalf("%s error: %v", name, res.Error)
+		}
+		wait, ok := queueWaitStat(res.Frames[0])
+		if !ok {
+			t.Fatalf("%s frame meta has no queue wait time", name)
+		}
+		if wait < 100 {
+			t.Errorf("%s queue wait time = %vms, want at least 100ms", name, wait)
+		}
+	}
+}
+
+func TestQueryData_AlertingJoinerRaisesPriority(t *testing.T) {
+	c := newLimitedCoalescer(t)
+	c.run("running", nil)
+	c.run("dashboard", nil)
+	c.run("shared", nil)
+	// the alerting query joins the dashboard query queued last, which overtakes the other one
+	c.run("shared", map[string]string{"FromAlert": "true"})
+	if got := c.searchOrder(); got != "running,shared,dashboard" {
+		t.Errorf("search order = %s, want running,shared,dashboard", got)
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryData_ErrorStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		body        string
+		wantStatus  backend.Status
+		wantMessage string
+	}{
+		{
+			name:        "sql error",
+			statusCode:  http.StatusBadRequest,
+			body:        `{"code":400,"message":"Search SQL not supported","error_detail":"field not found: foo","trace_id":"abc"}`,
+			wantStatus:  backend.StatusBadRequest,
+			wantMessage: "field not found: foo",
+		},
+		{name: "unauthorized", statusCode: http.StatusUnauthorized, body: `{"code":401,"message":"Unauthorized Access"}`, wantStatus: backend.StatusUnauthorized, wantMessage: "Unauthorized Access"},
+		{name: "forbidden", statusCode: http.StatusForbidden, body: `Forbidden`, wantStatus: backend.StatusForbidden, wantMessage: "Forbidden"},
+		{name: "too many requests", statusCode: http.StatusTooManyRequests, wantStatus: backend.StatusTooManyRequests, wantMessage: "Too Many Requests"},
+		{name: "timeout", statusCode: http.StatusGatewayTimeout, body: `{"code":504,"message":"Search timeout"}`, wantStatus: backend.StatusTimeout, wantMessage: "Search timeout"},
+		{name: "server error", statusCode: http.StatusInternalServerError, body: `{"code":500,"message":"internal error"}`, wantStatus: backend.StatusBadGateway, wantMessage: "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(tt.statusCode)
+				rw.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ds, settings := newTestDatasource(t, srv.URL, nil)
+			resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, map[string]any{
+				"queryType": "logs",
+				"rawSql":    "select * from log_stream",
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := resp.Responses["A"]
+			if res.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", res.Status, tt.wantStatus)
+			}
+			if res.ErrorSource != backend.ErrorSourceDownstream {
+				t.Errorf("ErrorSource = %v, want %v", res.ErrorSource, backend.ErrorSourceDownstream)
+			}
+			if res.Error == nil || !strings.Contains(res.Error.Error(), tt.wantMessage) {
+				t.Errorf("Error = %v, want it to contain %q", res.Error, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_Format(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Query struct {
+				Sql string `json:"sql"`
+			} `json:"query"`
+		}
+		json.NewDecoder(req.Body).Decode(&body)
+		if strings.HasPrefix(body.Query.Sql, "select *") {
+			rw.Write([]byte(`{"hits":[
+				{"_timestamp":1700000000000000,"level":"info","log":"a"},
+				{"_timestamp":1700000001000000,"level":"error","log":"b","code":500}
+			],"total":2}`))
+			return
+		}
+		// OpenObserve returns the buckets most recent first
+		rw.Write([]byte(`{"hits":[
+			{"gf_time":"2023-11-14T22:14:00","level":"info","count":3},
+			{"gf_time":"2023-11-14T22:14:00","level":"error","count":1},
+			{"gf_time":"2023-11-14T22:13:00","level":"info","count":2}
+		],"total":3}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"disableCache": true})
+	query := func(format, sql string) backend.DataResponse {
+		t.Helper()
+		req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": sql, "outputFormat": format})
+		resp, err := ds.QueryData(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.Responses["A"]
+	}
+	const (
+		selectAll = "select * from format_test"
+		aggregate = "select histogram(_timestamp) as gf_time, level, count(*) as count from format_test group by gf_time, level"
+	)
+	fieldNames := func(frame *data.Frame) string {
+		names := make([]string, 0, len(frame.Fields))
+		for _, field := range frame.Fields {
+			names = append(names, field.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	t.Run("auto", func(t *testing.T) {
+		res := query("", selectAll)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if got := res.Frames[0].Meta.PreferredVisualization; got != data.VisTypeLogs {
+			t.Errorf("visualization = %s, want logs", got)
+		}
+	})
+
+	t.Run("format of the SQL editor is ignored", func(t *testing.T) {
+		req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": selectAll, "format": "table"})
+		resp, err := ds.QueryData(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := resp.Responses["A"]
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if got := res.Frames[0].Meta.PreferredVisualization; got != data.VisTypeLogs {
+			t.Errorf("visualization = %s, want logs", got)
+		}
+	})
+
+	t.Run("table", func(t *testing.T) {
+		res := query("table", selectAll)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		frame := res.Frames[0]
+		if got, want := fieldNames(frame), "_timestamp,code,level,log"; got != want {
+			t.Errorf("fields = %s, want %s", got, want)
+		}
+		if typ := frame.Fields[0].Type(); typ != data.FieldTypeTime {
+			t.Errorf("_timestamp type = %s, want time", typ)
+		}
+		if got := frame.Fields[0].At(1).(time.Time); !got.Equal(time.UnixMilli(1700000001000)) {
+			t.Errorf("_timestamp = %s", got)
+		}
+		if frame.Rows() != 2 {
+			t.Errorf("rows = %d, want 2", frame.Rows())
+		}
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		res := query("logs", aggregate)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if got, want := fieldNames(res.Frames[0]), "time,body,labels"; got != want {
+			t.Errorf("fields = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("time series", func(t *testing.T) {
+		res := query("time_series", aggregate)
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		frame := res.Frames[0]
+		if frame.Meta.Type != data.FrameTypeTimeSeriesWide {
+			t.Errorf("frame type = %s, want %s", frame.Meta.Type, data.FrameTypeTimeSeriesWide)
+		}
+		if len(frame.Fields) != 3 || frame.Rows() != 2 {
+			t.Fatalf("frame = %s with %d rows, want a time field and a series per level", fieldNames(frame), frame.Rows())
+		}
+		times := frame.Fields[0]
+		if !times.At(0).(time.Time).Before(times.At(1).(time.Time)) {
+			t.Error("the time index is not sorted ascending")
+		}
+		for _, field := range frame.Fields[1:] {
+			if field.Name != "count" || field.Labels["level"] == "" {
+				t.Errorf("series %s%v, want count by level", field.Name, field.Labels)
+			}
+		}
+	})
+
+	t.Run("time series without time column", func(t *testing.T) {
+		res := query("time_series", "select level, count(*) as count from format_test group by level")
+		if res.Error == nil || !strings.Contains(res.Error.Error(), "requires a time column") {
+			t.Errorf("error = %v, want a missing time column error", res.Error)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		res := query("heatmap", selectAll)
+		if res.Error == nil || res.Status != backend.StatusBadRequest {
+			t.Errorf("status = %d, error = %v, want a bad request", res.Status, res.Error)
+		}
+	})
+}
+
+func TestQueryData_NullValues(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// OpenObserve returns null for the columns a row has no value of
+		rw.Write([]byte(`{"hits":[
+			{"_timestamp":1700000000000000,"level":null,"code":500},
+			{"_timestamp":1700000001000000,"level":"error","code":null},
+			{"_timestamp":1700000002000000,"level":null}
+		],"total":3}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"disableCache": true})
+	req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": "select _timestamp, level, code from format_test"})
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	frame := res.Frames[0]
+	if frame.Rows() != 3 {
+		t.Fatalf("rows = %d, want 3", frame.Rows())
+	}
+	// null values are filled with the zero value of their column
+	for name, want := range map[string][]any{"level": {"", "error", ""}, "code": {500.0, 0.0, 0.0}} {
+		field, _ := frame.FieldByName(name)
+		if field == nil {
+			t.Fatalf("no %s field in frame", name)
+		}
+		for i := range want {
+			got, _ := field.ConcreteAt(i)
+			if got != want[i] {
+				t.Errorf("%s[%d] = %v, want %v", name, i, got, want[i])
+			}
+		}
+	}
+}
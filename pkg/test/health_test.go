@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCheckHealth_Diagnostics(t *testing.T) {
+	tests := []struct {
+		name        string
+		database    string
+		override    map[string]http.HandlerFunc // path --> handler replacing the fake one
+		wantStatus  backend.HealthStatus
+		wantMessage string
+	}{
+		{
+			name:        "working",
+			database:    "default",
+			wantStatus:  backend.HealthStatusOk,
+			wantMessage: `OpenObserve v0.15.0, organization "default" has 2 logs, 0 metrics and 0 traces streams`,
+		},
+		{
+			name:     "invalid credentials",
+			database: "default",
+			override: map[string]http.HandlerFunc{"/api/clusters": func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusUnauthorized)
+			}},
+			wantStatus:  backend.HealthStatusError,
+			wantMessage: "Authentication failed",
+		},
+		{
+			name:        "unknown organization",
+			database:    "missing",
+			wantStatus:  backend.HealthStatusError,
+			wantMessage: `Organization "missing" not found or the user is not a member of it, available organizations: default`,
+		},
+		{
+			name:     "missing stream permission",
+			database: "default",
+			override: map[string]http.HandlerFunc{"/api/default/streams": func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Query().Get("type") == "traces" {
+					rw.WriteHeader(http.StatusForbidden)
+					return
+				}
+				rw.Write([]byte(`{"list":[]}`))
+			}},
+			wantStatus:  backend.HealthStatusOk,
+			wantMessage: "cannot list traces streams: Access denied",
+		},
+		{
+			name:     "search stream not available",
+			database: "default",
+			override: map[string]http.HandlerFunc{"/api/default/_search_stream": func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusNotFound)
+			}},
+			wantStatus:  backend.HealthStatusOk,
+			wantMessage: "_search_stream is not available",
+		},
+		{
+			name:     "search stream support unknown",
+			database: "default",
+			override: map[string]http.HandlerFunc{"/api/default/_search_stream": func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			}},
+			wantStatus:  backend.HealthStatusOk,
+			wantMessage: "could not detect whether _search_stream is available",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newOpenObserveMux()
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if handler, ok := tt.override[req.URL.Path]; ok {
+					handler(rw, req)
+					return
+				}
+				mux.ServeHTTP(rw, req)
+			}))
+			defer srv.Close()
+
+			ds, _ := newTestDatasource(t, srv.URL, map[string]any{"database": tt.database})
+			res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v, message: %s", res.Status, tt.wantStatus, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMessage) {
+				t.Errorf("Message = %q, want it to contain %q", res.Message, tt.wantMessage)
+			}
+			var details map[string]any
+			if err := json.Unmarshal(res.JSONDetails, &details); err != nil {
+				t.Errorf("JSONDetails is not valid JSON: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckHealth_SearchStreamProbeBypassesCircuitBreaker(t *testing.T) {
+	var probes atomic.Int64
+	mux := newOpenObserveMux()
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/default/_search_stream" {
+			probes.Add(1)
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mux.ServeHTTP(rw, req)
+	}))
+	defer srv.Close()
+
+	ds, _ := newTestDatasource(t, srv.URL, map[string]any{"circuitBreakerFailures": 1})
+	for i := 0; i < 2; i++ {
+		res, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Status != backend.HealthStatusOk {
+			t.Fatalf("CheckHealth() #%d status = %v, message: %s", i+1, res.Status, res.Message)
+		}
+		var details map[string]any
+		if err := json.Unmarshal(res.JSONDetails, &details); err != nil {
+			t.Fatal(err)
+		}
+		if available, ok := details["searchStreamAvailable"]; !ok || available != nil {
+			t.Errorf("searchStreamAvailable = %v, want null", available)
+		}
+	}
+	if got := probes.Load(); got != 2 {
+		t.Errorf("_search_stream probes = %d, want 2", got)
+	}
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_IncrementalRefresh(t *testing.T) {
+	const timeSeriesSql = "select histogram(_timestamp, '1 minute') as gf_time, count(*) as cnt from log_stream group by gf_time order by gf_time"
+	var (
+		mu       sync.Mutex
+		searches []openobserve.Query
+	)
+	// every search counts one row per minute bucket overlapping its time range, tagged with the search number
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		searches = append(searches, body.Query)
+		n := len(searches)
+		mu.Unlock()
+
+		minute := time.Minute.Microseconds()
+		hits := []map[string]any{}
+		for bucket := body.StartTime - body.StartTime%minute; bucket < body.EndTime; bucket += minute {
+			hits = append(hits, map[string]any{
+				"gf_time": time.UnixMicro(bucket).UTC().Format("2006-01-02T15:04:05"),
+				"cnt":     n,
+			})
+		}
+		json.NewEncoder(rw).Encode(map[string]any{"hits": hits, "total": len(hits)})
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"disableCache": true, "incrementalOverlap": 300})
+	query := func(from, to time.Time) *data.Frame {
+		t.Helper()
+		req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": timeSeriesSql, "incremental": true, "size": 1000})
+		req.Queries[0].TimeRange.From, req.Queries[0].TimeRange.To = from, to
+		resp, err := ds.QueryData(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := resp.Responses["A"]
+		if res.Error != nil {
+			t.Fatalf("QueryData() error = %v", res.Error)
+		}
+		return res.Frames[0]
+	}
+
+	to := time.Now().Truncate(time.Minute).Add(30 * time.Second)
+	query(to.Add(-time.Hour), to)
+	// the dashboard refreshes 2 minutes later
+	from, to := to.Add(-time.Hour+2*time.Minute), to.Add(2*time.Minute)
+	frame := query(from, to)
+
+	if len(searches) != 2 {
+		t.Fatalf("searches = %d, want 2", len(searches))
+	}
+	// the refresh searches from the previous end moved back by the overlap, aligned to the minute
+	wantSliceStart := to.Add(-2*time.Minute - 5*time.Minute).Truncate(time.Minute).UnixMicro()
+	if searches[1].StartTime != wantSliceStart {
+		t.Errorf("refresh start = %v, want %v", time.UnixMicro(searches[1].StartTime), time.UnixMicro(wantSliceStart))
+	}
+
+	// same buckets as a full search of the new time range, the buckets of the slice from the refresh
+	times, counts := frame.Fields[0], frame.Fields[1]
+	wantBuckets := 0
+	for bucket := from.Truncate(time.Minute); bucket.Before(to); bucket = bucket.Add(time.Minute) {
+		wantBuckets++
+	}
+	if frame.Rows() != wantBuckets {
+		t.Fatalf("rows = %d, want %d", frame.Rows(), wantBuckets)
+	}
+	for i := range frame.Rows() {
+		bucket := times.At(i).(time.Time)
+		if i > 0 && !bucket.After(times.At(i-1).(time.Time)) {
+			t.Errorf("row %d: bucket %v is not after the previous one", i, bucket)
+		}
+		wantCount := 1.0
+		if bucket.UnixMicro() >= wantSliceStart {
+			wantCount = 2
+		}
+		if counts.At(i) != wantCount {
+			t.Errorf("row %d: bucket %v cnt = %v, want %v", i, bucket, counts.At(i), wantCount)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryData_AlertingQueriesRunFirst(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		order = append(order, strings.TrimPrefix(body.Sql, "select * from "))
+		mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		rw.Write([]byte(`{"hits":[]}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"maxConcurrentQueries": 1, "disableCache": true})
+	responses := make(map[string]backend.DataResponse)
+	var wg sync.WaitGroup
+	run := func(stream string, headers map[string]string) {
+		defer wg.Done()
+		req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": "select * from " + stream})
+		req.Headers = headers
+		resp, err := ds.QueryData(context.Background(), req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		responses[stream] = resp.Responses["A"]
+		mu.Unlock()
+	}
+
+	// the dashboard query waits for the running one, the alerting query arriving later overtakes it
+	wg.Add(3)
+	go run("running", nil)
+	time.Sleep(30 * time.Millisecond)
+	go run("dashboard", nil)
+	time.Sleep(30 * time.Millisecond)
+	go run("alerting", map[string]string{"FromAlert": "true"})
+	wg.Wait()
+
+	if got := strings.Join(order, ","); got != "running,alerting,dashboard" {
+		t.Errorf("search order = %s, want running,alerting,dashboard", got)
+	}
+	frame := responses["dashboard"].Frames[0]
+	for _, stat := range frame.Meta.Stats {
+		if stat.DisplayName == "Queue wait time" {
+			if stat.Value < 100 {
+				t.Errorf("queue wait time = %vms, want at least 100ms", stat.Value)
+			}
+			return
+		}
+	}
+	t.Error("frame meta has no queue wait time")
+}
+
+func TestQueryData_QueryTypeConcurrency(t *testing.T) {
+	var (
+		mu                sync.Mutex
+		maxLogs           int
+		runningByType     = map[string]int{}
+		metricsOverlapped bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		streamType := req.URL.Query().Get("type")
+		mu.Lock()
+		runningByType[streamType]++
+		maxLogs = max(maxLogs, runningByType["logs"])
+		if runningByType["metrics"] > 0 && runningByType["logs"] > 0 {
+			metricsOverlapped = true
+		}
+		mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		runningByType[streamType]--
+		mu.Unlock()
+		rw.Write([]byte(`{"hits":[]}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{
+		"maxConcurrentQueries": 3,
+		"queryTypeConcurrency": map[string]int{"logs": 1},
+		"disableCache":         true,
+	})
+	var wg sync.WaitGroup
+	for i, queryType := range []string{"logs", "logs", "logs", "metrics"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := newQueryDataRequest(settings, map[string]any{"queryType": queryType, "rawSql": fmt.Sprintf("select * from stream_%d", i)})
+			req.Queries[0].QueryType = queryType
+			resp, err := ds.QueryData(context.Background(), req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := resp.Responses["A"].Error; err != nil {
+				t.Errorf("query %d error: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxLogs != 1 {
+		t.Errorf("concurrent logs queries = %d, want 1", maxLogs)
+	}
+	if !metricsOverlapped {
+		t.Error("metrics query waited for the logs queries")
+	}
+}
+
+func TestQueryData_PartitionRequestsLimited(t *testing.T) {
+	var (
+		mu                  sync.Mutex
+		running, maxRunning int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/default/_search_partition", func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchPartitionRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		third := (body.EndTime - body.StartTime) / 3
+		json.NewEncoder(rw).Encode(map[string]any{
+			"partitions": [][2]int64{
+				{body.EndTime - third, body.EndTime},
+				{body.StartTime + third, body.EndTime - third},
+				{body.StartTime, body.StartTime + third},
+			},
+		})
+	})
+	mux.HandleFunc("/api/default/_search", func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		rw.Write([]byte(`{"hits":[]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"maxConcurrentQueries": 1, "partitionConcurrency": 3})
+	resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, map[string]any{
+		"queryType":   "logs",
+		"rawSql":      "select * from log_stream",
+		"partitioned": true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("QueryData() error = %v", res.Error)
+	}
+	if maxRunning != 1 {
+		t.Errorf("concurrent partition searches = %d, want 1", maxRunning)
+	}
+	for _, stat := range res.Frames[0].Meta.Stats {
+		if stat.DisplayName == "Queue wait time" {
+			if stat.Value < 50 {
+				t.Errorf("queue wait time = %vms, want at least 50ms", stat.Value)
+			}
+			return
+		}
+	}
+	t.Error("frame meta has no queue wait time")
+}
+
+func TestQueryData_QueryConcurrency(t *testing.T) {
+	const queries = 15
+	tests := []struct {
+		name                 string
+		maxConcurrentQueries int
+	}{
+		{name: "unlimited", maxConcurrentQueries: -1},
+		{name: "above the SDK cap of 10", maxConcurrentQueries: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu                  sync.Mutex
+				running, maxRunning int
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+				time.Sleep(100 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				rw.Write([]byte(`{"hits":[]}`))
+			}))
+			defer srv.Close()
+
+			ds, settings := newTestDatasource(t, srv.URL, map[string]any{"maxConcurrentQueries": tt.maxConcurrentQueries, "disableCache": true})
+			req := newQueryDataRequest(settings, nil)
+			req.Queries = nil
+			for i := range queries {
+				query := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": fmt.Sprintf("select * from stream_%d", i)}).Queries[0]
+				query.RefID = fmt.Sprint("Q", i)
+				req.Queries = append(req.Queries, query)
+			}
+			resp, err := ds.QueryData(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for refID, res := range resp.Responses {
+				if res.Error != nil {
+					t.Errorf("query %s error: %v", refID, res.Error)
+				}
+			}
+			if len(resp.Responses) != queries {
+				t.Errorf("responses = %d, want %d", len(resp.Responses), queries)
+			}
+			if maxRunning != queries {
+				t.Errorf("concurrent queries = %d, want %d", maxRunning, queries)
+			}
+		})
+	}
+}
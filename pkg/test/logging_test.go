@@ -0,0 +1,67 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "Basic dXNlcjpzZWNyZXQ=")
+	headers.Set("Cookie", "grafana_session=abc")
+	headers.Set("X-Id-Token", "id-token")
+	headers.Set("X-Api-Key", "key")
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	redacted := openobserve.RedactHeaders(headers)
+	for _, name := range []string{"Authorization", "Cookie", "X-Id-Token", "X-Api-Key"} {
+		if got := redacted.Get(name); got != "[REDACTED]" {
+			t.Errorf("%s = %q, want it redacted", name, got)
+		}
+	}
+	for _, name := range []string{"Content-Type", "Traceparent"} {
+		if got := redacted.Get(name); got != headers.Get(name) {
+			t.Errorf("%s = %q, want %q", name, got, headers.Get(name))
+		}
+	}
+	if headers.Get("Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Error("the headers of the request were modified")
+	}
+}
+
+func TestWithLogAttributes(t *testing.T) {
+	// the attributes of the parent have spare capacity, as the ones appended by the SDK may
+	parent := append(make([]any, 0, 16), "dsUid", "ds")
+	ctx, cancel := context.WithCancel(log.WithContextualAttributes(context.Background(), parent))
+	defer cancel()
+
+	// the attributes of concurrent queries share their parent's, run with -race
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queryCtx := openobserve.WithLogAttributes(ctx, "refId", fmt.Sprint(i))
+			queryCtx = openobserve.WithLogAttributes(queryCtx, "openobserveTraceId", fmt.Sprint("trace", i))
+			want := []any{"dsUid", "ds", "refId", fmt.Sprint(i), "openobserveTraceId", fmt.Sprint("trace", i)}
+			if got := log.ContextualAttributesFromContext(queryCtx); !slices.Equal(got, want) {
+				t.Errorf("attributes = %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	queryCtx := openobserve.WithLogAttributes(ctx, "refId", "A")
+	cancel()
+	if queryCtx.Err() == nil {
+		t.Error("the context with log attributes was not cancelled with its parent")
+	}
+}
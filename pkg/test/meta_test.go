@@ -0,0 +1,89 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_SearchMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{
+			"hits":[{"_timestamp":1,"log":"a"},{"_timestamp":2,"log":"b"}],
+			"total":2,"took":42,"took_detail":{"wait_in_queue":3,"search_took":30},
+			"scan_size":12,"scan_records":3400,"cached_ratio":50,"result_cache_ratio":25,
+			"trace_id":"o2trace","is_partial":true
+		}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"disableCache": true})
+	req := newQueryDataRequest(settings, map[string]any{
+		"queryType":    "logs",
+		"rawSql":       "select * from meta_test",
+		"adhocFilters": []map[string]string{{"key": "level", "operator": "=", "value": "error"}},
+	})
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	meta := res.Frames[0].Meta
+	if meta == nil {
+		t.Fatal("no frame meta")
+	}
+
+	if want := "select * from meta_test where level = 'error'"; meta.ExecutedQueryString != want {
+		t.Errorf("executed query = %q, want %q", meta.ExecutedQueryString, want)
+	}
+
+	stats := map[string]data.QueryStat{}
+	for _, stat := range meta.Stats {
+		stats[stat.DisplayName] = stat
+	}
+	for _, want := range []struct {
+		name  string
+		unit  string
+		value float64
+	}{
+		{"Took", "ms", 42},
+		{"OpenObserve queue time", "ms", 3},
+		{"Search time", "ms", 30},
+		{"Scan size", "decmbytes", 12},
+		{"Scanned records", "short", 3400},
+		{"Cached ratio", "percent", 50},
+		{"Result cache ratio", "percent", 25},
+	} {
+		stat, ok := stats[want.name]
+		if !ok {
+			t.Errorf("no %s stat", want.name)
+			continue
+		}
+		if stat.Unit != want.unit || stat.Value != want.value {
+			t.Errorf("%s = %v %s, want %v %s", want.name, stat.Value, stat.Unit, want.value, want.unit)
+		}
+	}
+
+	var partial, rows bool
+	for _, notice := range meta.Notices {
+		switch {
+		case notice.Severity == data.NoticeSeverityWarning && strings.HasPrefix(notice.Text, "Partial results"):
+			partial = true
+		case notice.Severity == data.NoticeSeverityInfo:
+			rows = strings.Contains(notice.Text, "2 of 2 matching rows") && strings.Contains(notice.Text, "o2trace")
+		}
+	}
+	if !partial {
+		t.Errorf("notices = %+v, want a partial results warning", meta.Notices)
+	}
+	if !rows {
+		t.Errorf("notices = %+v, want the returned and matching rows with the trace id", meta.Notices)
+	}
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/streams") {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.Write([]byte(`{"hits":[{"_timestamp":1,"log":"a"},{"_timestamp":2,"log":"b"}],"total":2}`))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, nil)
+	req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": "select * from metrics_test"})
+	for range 2 {
+		if _, err := ds.QueryData(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fallback := newQueryDataRequest(settings, map[string]any{"rawSql": "\\dt logs"})
+	fallback.Queries[0].QueryType = ""
+	if _, err := ds.QueryData(context.Background(), fallback); err != nil {
+		t.Fatal(err)
+	}
+
+	// the metrics are registered with the default registry, which the SDK serves to Grafana
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	// and which the datasource returns when its metrics are collected
+	collected, err := ds.CollectMetrics(context.Background(), &backend.CollectMetricsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for source, metrics := range map[string]string{"scrape": rec.Body.String(), "CollectMetrics": string(collected.PrometheusMetrics)} {
+		for _, want := range []string{
+			`openobserve_datasource_request_duration_seconds_count{endpoint="_search",query_type="logs"}`,
+			`openobserve_datasource_response_bytes_total{endpoint="_search",query_type="logs"}`,
+			`openobserve_datasource_hits_decoded_total{query_type="logs"}`,
+			`openobserve_datasource_transform_duration_seconds_count{query_type="logs"}`,
+			`openobserve_datasource_cache_requests_total{cache="result",query_type="logs",result="hit"}`,
+			`openobserve_datasource_cache_requests_total{cache="result",query_type="logs",result="miss"}`,
+			`openobserve_datasource_request_errors_total{endpoint="streams",query_type="fallback",status="500"}`,
+		} {
+			if !strings.Contains(metrics, want) {
+				t.Errorf("%s metrics do not contain %s", source, want)
+			}
+		}
+	}
+}
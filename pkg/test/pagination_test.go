@@ -0,0 +1,136 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryData_Pagination(t *testing.T) {
+	const total = 25000
+	tests := []struct {
+		name       string
+		jsonData   map[string]any
+		query      map[string]any
+		wantPages  [][2]int64 // from and size of the requested pages
+		wantRows   int
+		wantNotice string
+	}{
+		{
+			name:       "single page by default",
+			query:      map[string]any{"rawSql": "select * from log_stream"},
+			wantPages:  [][2]int64{{0, 200}},
+			wantRows:   200,
+			wantNotice: "showing 200 of 25000 rows",
+		},
+		{
+			name:      "single page cut by the SQL LIMIT",
+			query:     map[string]any{"rawSql": "select * from log_stream limit 100"},
+			wantPages: [][2]int64{{0, 100}},
+			wantRows:  100,
+		},
+		{
+			name:       "single page below the SQL LIMIT",
+			jsonData:   map[string]any{"maxRows": 5000},
+			query:      map[string]any{"rawSql": "select * from log_stream limit 8000"},
+			wantPages:  [][2]int64{{0, 5000}},
+			wantRows:   5000,
+			wantNotice: "at most 5000 rows per query",
+		},
+		{
+			name:      "pages until the SQL LIMIT",
+			query:     map[string]any{"rawSql": "select * from log_stream limit 15000"},
+			wantPages: [][2]int64{{0, 10000}, {10000, 5000}},
+			wantRows:  15000,
+		},
+		{
+			name:      "pages until the total",
+			query:     map[string]any{"rawSql": "select * from log_stream", "paginate": true},
+			wantPages: [][2]int64{{0, 10000}, {10000, 10000}, {20000, 10000}},
+			wantRows:  total,
+		},
+		{
+			name:       "pages until the row ceiling",
+			jsonData:   map[string]any{"maxRows": 12000},
+			query:      map[string]any{"rawSql": "select * from log_stream", "paginate": true},
+			wantPages:  [][2]int64{{0, 10000}, {10000, 2000}},
+			wantRows:   12000,
+			wantNotice: "at most 12000 rows per query",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				pages [][2]int64
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				var body openobserve.SearchRequestBody
+				json.NewDecoder(req.Body).Decode(&body)
+				mu.Lock()
+				if len(pages) == 0 && len(tt.wantPages) > 1 && !body.TrackTotalHits {
+					t.Error("first page of a paginated search does not track the total hits")
+				}
+				pages = append(pages, [2]int64{body.From, body.Size})
+				mu.Unlock()
+
+				hits := make([]map[string]any, 0, body.Size)
+				for n := body.From; n < min(body.From+body.Size, total); n++ {
+					hits = append(hits, map[string]any{"_timestamp": n, "n": n})
+				}
+				// OpenObserve only counts the matching hits on request, the returned ones otherwise
+				respTotal := len(hits)
+				if body.TrackTotalHits {
+					respTotal = total
+				}
+				json.NewEncoder(rw).Encode(map[string]any{"hits": hits, "total": respTotal})
+			}))
+			defer srv.Close()
+
+			tt.query["queryType"] = "logs"
+			ds, settings := newTestDatasource(t, srv.URL, tt.jsonData)
+			resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := resp.Responses["A"]
+			if res.Error != nil {
+				t.Fatalf("QueryData() error = %v", res.Error)
+			}
+			if len(pages) != len(tt.wantPages) {
+				t.Fatalf("pages = %v, want %v", pages, tt.wantPages)
+			}
+			for i := range pages {
+				if pages[i] != tt.wantPages[i] {
+					t.Errorf("page %d = %v, want %v", i, pages[i], tt.wantPages[i])
+				}
+			}
+			frame := res.Frames[0]
+			if frame.Rows() != tt.wantRows {
+				t.Errorf("rows = %d, want %d", frame.Rows(), tt.wantRows)
+			}
+			var notices []string
+			if frame.Meta != nil {
+				for _, notice := range frame.Meta.Notices {
+					if notice.Severity == data.NoticeSeverityInfo {
+						continue // the rows returned out of the matching ones, see TestQueryData_SearchMeta
+					}
+					notices = append(notices, notice.Text)
+				}
+			}
+			if tt.wantNotice == "" && len(notices) > 0 {
+				t.Errorf("unexpected notices %v", notices)
+			}
+			if tt.wantNotice != "" && (len(notices) != 1 || !strings.Contains(notices[0], tt.wantNotice)) {
+				t.Errorf("notices = %v, want one containing %q", notices, tt.wantNotice)
+			}
+		})
+	}
+}
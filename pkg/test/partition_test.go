@@ -0,0 +1,198 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+)
+
+func TestQueryData_PartitionedSearch(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          map[string]any
+		wantPartitions bool     // whether the partitions are requested
+		wantSearches   int32    // number of _search calls
+		wantLevels     []string // level of the returned rows, in order
+	}{
+		{
+			name:           "hits in partition order, later partitions skipped",
+			query:          map[string]any{"rawSql": "select * from log_stream limit 2", "partitioned": true},
+			wantPartitions: true,
+			wantSearches:   1,
+			wantLevels:     []string{"error", "info"},
+		},
+		{
+			name:           "hits sorted by ORDER BY and cut to LIMIT",
+			query:          map[string]any{"rawSql": "select _timestamp, level from log_stream order by level asc limit 4", "partitioned": true},
+			wantPartitions: true,
+			wantSearches:   3,
+			wantLevels:     []string{"debug", "debug", "debug", "error"},
+		},
+		{
+			name:         "aggregation across time is not partitioned",
+			query:        map[string]any{"rawSql": "select level, count(*) as n from log_stream group by level", "partitioned": true},
+			wantSearches: 1,
+		},
+		{
+			name:         "time buckets without an explicit interval are not partitioned",
+			query:        map[string]any{"rawSql": "select histogram(_timestamp) as ts, count(*) as n from log_stream group by ts", "partitioned": true},
+			wantSearches: 1,
+		},
+		{
+			name:         "aggregates which cannot be merged are not partitioned",
+			query:        map[string]any{"rawSql": "select histogram(_timestamp, '1 minute') as ts, avg(took) as n from log_stream group by ts", "partitioned": true},
+			wantSearches: 1,
+		},
+		{
+			name:         "short time range is not partitioned automatically",
+			query:        map[string]any{"rawSql": "select * from log_stream"},
+			wantSearches: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var partitionCalls, searches atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/default/_search_partition", func(rw http.ResponseWriter, req *http.Request) {
+				partitionCalls.Add(1)
+				var body openobserve.SearchPartitionRequestBody
+				json.NewDecoder(req.Body).Decode(&body)
+				third := (body.EndTime - body.StartTime) / 3
+				json.NewEncoder(rw).Encode(map[string]any{
+					"trace_id": "partition-trace",
+					"partitions": [][2]int64{
+						{body.EndTime - third, body.EndTime},
+						{body.StartTime + third, body.EndTime - third},
+						{body.StartTime, body.StartTime + third},
+					},
+					"order_by": "desc",
+				})
+			})
+			mux.HandleFunc("/api/default/_search", func(rw http.ResponseWriter, req *http.Request) {
+				searches.Add(1)
+				var body openobserve.SearchRequestBody
+				json.NewDecoder(req.Body).Decode(&body)
+				hits := []map[string]any{
+					{"_timestamp": body.EndTime - 1, "level": "error"},
+					{"_timestamp": body.EndTime - 2, "level": "info"},
+					{"_timestamp": body.EndTime - 3, "level": "debug"},
+				}
+				json.NewEncoder(rw).Encode(map[string]any{"hits": hits[:min(int(body.Size), len(hits))], "total": len(hits), "took": 10})
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			tt.query["queryType"] = "logs"
+			ds, settings := newTestDatasource(t, srv.URL, map[string]any{"partitionConcurrency": 1})
+			resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := resp.Responses["A"]
+			if res.Error != nil {
+				t.Fatalf("QueryData() error = %v", res.Error)
+			}
+			if got := partitionCalls.Load() > 0; got != tt.wantPartitions {
+				t.Errorf("partitions requested = %t, want %t", got, tt.wantPartitions)
+			}
+			if got := searches.Load(); got != tt.wantSearches {
+				t.Errorf("searches = %d, want %d", got, tt.wantSearches)
+			}
+			if tt.wantLevels == nil {
+				return
+			}
+			frame := res.Frames[0]
+			if frame.Rows() != len(tt.wantLevels) {
+				t.Fatalf("rows = %d, want %d", frame.Rows(), len(tt.wantLevels))
+			}
+			for i, level := range tt.wantLevels {
+				// log frames carry the row in their labels, table frames a level column
+				var got any
+				if field, _ := frame.FieldByName("level"); field != nil {
+					got = field.At(i)
+				} else {
+					var labels map[string]any
+					json.Unmarshal(frame.Fields[2].At(i).(json.RawMessage), &labels)
+					got = labels["level"]
+				}
+				if got != level {
+					t.Errorf("row %d level = %v, want %s", i, got, level)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryData_PartitionedTimeBuckets(t *testing.T) {
+	const interval = int64(time.Minute / time.Microsecond)
+	var searchStart, searchEnd int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/default/_search_partition", func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchPartitionRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		searchStart, searchEnd = body.StartTime, body.EndTime
+		// split the time range in the middle of a bucket
+		boundary := body.StartTime + (body.EndTime-body.StartTime)/2
+		boundary = boundary - boundary%interval + interval/2
+		json.NewEncoder(rw).Encode(map[string]any{
+			"partitions": [][2]int64{{boundary, body.EndTime}, {body.StartTime, boundary}},
+		})
+	})
+	mux.HandleFunc("/api/default/_search", func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		// one row per microsecond: the count of a bucket is the part of it within the time range
+		hits := []map[string]any{}
+		for bucket := body.StartTime - body.StartTime%interval; bucket < body.EndTime; bucket += interval {
+			hits = append(hits, map[string]any{
+				"ts": time.UnixMicro(bucket).UTC().Format("2006-01-02T15:04:05"),
+				"c":  min(bucket+interval, body.EndTime) - max(bucket, body.StartTime),
+			})
+		}
+		json.NewEncoder(rw).Encode(map[string]any{"hits": hits, "total": len(hits)})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, nil)
+	resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, map[string]any{
+		"queryType":   "logs",
+		"rawSql":      "select histogram(_timestamp, '1 minute') as ts, count(*) as c from log_stream group by ts order by ts asc",
+		"partitioned": true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("QueryData() error = %v", res.Error)
+	}
+	if searchStart == 0 {
+		t.Fatal("partitions were not requested")
+	}
+
+	frame := res.Frames[0]
+	wantBuckets := int((searchEnd-1)/interval - searchStart/interval + 1)
+	if frame.Rows() != wantBuckets {
+		t.Errorf("rows = %d, want %d buckets, each once", frame.Rows(), wantBuckets)
+	}
+	field, _ := frame.FieldByName("c")
+	if field == nil {
+		t.Fatalf("no count field in frame %v", frame)
+	}
+	var total float64
+	for i := 0; i < field.Len(); i++ {
+		if value, _ := field.NullableFloatAt(i); value != nil {
+			total += *value
+		}
+	}
+	if int64(total) != searchEnd-searchStart {
+		t.Errorf("sum of counts = %d, want %d", int64(total), searchEnd-searchStart)
+	}
+}
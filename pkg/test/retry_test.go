@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+)
+
+func TestListStreams_Retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		status       int
+		maxAttempts  int
+		wantErr      bool
+		wantAttempts int32
+	}{
+		{name: "retries unavailable", failures: 2, status: http.StatusServiceUnavailable, maxAttempts: 3, wantAttempts: 3},
+		{name: "retries too many requests", failures: 1, status: http.StatusTooManyRequests, maxAttempts: 3, wantAttempts: 2},
+		{name: "gives up after max attempts", failures: 5, status: http.StatusBadGateway, maxAttempts: 3, wantErr: true, wantAttempts: 3},
+		{name: "retries disabled", failures: 1, status: http.StatusServiceUnavailable, maxAttempts: 1, wantErr: true, wantAttempts: 1},
+		{name: "client errors are not retried", failures: 1, status: http.StatusBadRequest, maxAttempts: 3, wantErr: true, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if attempts.Add(1) <= tt.failures {
+					rw.Header().Set("Retry-After", "0")
+					rw.WriteHeader(tt.status)
+					return
+				}
+				rw.Write([]byte(`{"list":[{"name":"log_stream"}]}`))
+			}))
+			defer srv.Close()
+
+			client := openobserve.NewOpenObserveClient(srv.URL, openobserve.ClientOptions{
+				HTTPClient: srv.Client(),
+				RetryPolicy: openobserve.RetryPolicy{
+					MaxAttempts:    tt.maxAttempts,
+					InitialBackoff: time.Millisecond,
+					MaxBackoff:     10 * time.Millisecond,
+				},
+			})
+			resp, err := client.ListStreams(context.Background(), &openobserve.ListStreamRequestParam{Organization: "default"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListStreams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(resp.List) != 1 {
+				t.Errorf("ListStreams() returned %d streams, want 1", len(resp.List))
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// newOpenObserveMux returns a handler faking the OpenObserve endpoints used by the health check
+// for the "default" organization
+func newOpenObserveMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/clusters", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/config", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"version":"v0.15.0"}`))
+	})
+	mux.HandleFunc("/api/organizations", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"data":[{"identifier":"default","name":"default"}]}`))
+	})
+	mux.HandleFunc("/api/default/streams", func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("type") == "logs" {
+			rw.Write([]byte(`{"list":[{"name":"log_stream"},{"name":"audit"}]}`))
+			return
+		}
+		rw.Write([]byte(`{"list":[]}`))
+	})
+	mux.HandleFunc("/api/default/_search_stream", func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	})
+	return mux
+}
+
+// newTestDatasource creates a datasource pointing to url with retries disabled, for the "default"
+// organization unless jsonData sets another one
+func newTestDatasource(t *testing.T, url string, jsonData map[string]any) (*plugin.Datasource, backend.DataSourceInstanceSettings) {
+	if jsonData == nil {
+		jsonData = map[string]any{}
+	}
+	if _, ok := jsonData["database"]; !ok {
+		jsonData["database"] = "default"
+	}
+	jsonData["retryMaxAttempts"] = 1
+	rawJsonData, err := json.Marshal(jsonData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := backend.DataSourceInstanceSettings{
+		URL:                     url,
+		User:                    "user",
+		JSONData:                rawJsonData,
+		DecryptedSecureJSONData: map[string]string{"password": "secret"},
+	}
+	instance, err := plugin.NewDatasource(context.Background(), settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance.(*plugin.Datasource), settings
+}
+
+// newQueryDataRequest builds a request with a single query of refID A
+func newQueryDataRequest(settings backend.DataSourceInstanceSettings, query map[string]any) *backend.QueryDataRequest {
+	rawQuery, _ := json.Marshal(query)
+	return &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{DataSourceInstanceSettings: &settings},
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			QueryType: "logs",
+			JSON:      rawQuery,
+			TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		}},
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+)
+
+func newSearchParam(enableSSE bool) (*openobserve.SearchRequestParam, *openobserve.SearchRequestBody) {
+	return &openobserve.SearchRequestParam{
+		Organization: "default",
+		StreamType:   openobserve.LogsStream,
+		SearchType:   openobserve.SearchTypeUI,
+		EnableSSE:    enableSSE,
+	}, &openobserve.SearchRequestBody{
+		Query: openobserve.Query{
+			Sql:  "select * from \"log_stream\"",
+			Size: 100,
+		},
+		SearchType: openobserve.SearchTypeUI,
+	}
+}
+
+func TestSearch_SSECancellation(t *testing.T) {
+	traceparents := make(chan string, 1)
+	cancelled := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/default/_search_stream", func(rw http.ResponseWriter, req *http.Request) {
+		traceparents <- req.Header.Get("traceparent")
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":1}]}\n\n"))
+		rw.(http.Flusher).Flush()
+		<-req.Context().Done() // never finish the stream on our own
+	})
+	mux.HandleFunc("DELETE /api/default/query_manager/{traceID}", func(rw http.ResponseWriter, req *http.Request) {
+		cancelled <- req.PathValue("traceID")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := openobserve.NewOpenObserveClient(srv.URL, openobserve.ClientOptions{
+		HTTPClient:  srv.Client(),
+		RetryPolicy: openobserve.DefaultRetryPolicy,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	param, body := newSearchParam(true)
+	_, err := client.Search(ctx, param, body)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Search() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Search() returned after %v, want it to stop at the context deadline", elapsed)
+	}
+
+	traceparent := <-traceparents
+	select {
+	case traceID := <-cancelled:
+		if !strings.Contains(traceparent, traceID) {
+			t.Errorf("cancelled trace id %q does not match traceparent %q", traceID, traceparent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("abandoned search was not cancelled on the OpenObserve side")
+	}
+}
+
+func TestSearch_SSEEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		stream     string
+		wantErr    error
+		wantStatus int // status of the expected APIError, if any
+		wantHits   int
+		wantTotal  int
+		wantTook   int
+		wantScan   int
+		wantPart   bool
+	}{
+		{
+			name: "hits and metadata of several partitions",
+			stream: ": keep-alive\n\n" +
+				"event: search_response_metadata\ndata: {\"results\":{\"total\":3,\"took\":5,\"scan_size\":10,\"trace_id\":\"t1\"}}\n\n" +
+				"event: search_response_hits\nid: 1\ndata: {\"hits\":[{\"_timestamp\":1},{\"_timestamp\":2}]}\n\n" +
+				"event: progress\ndata: {\"percent\":50}\n\n" +
+				"event: search_response_metadata\ndata: {\"results\":{\"total\":1,\"took\":7,\"scan_size\":4,\"is_partial\":true}}\n\n" +
+				"event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":3}]}\n\n" +
+				"event: end\ndata: [[DONE]]\n\n" +
+				"event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":4}]}\n\n",
+			wantHits:  3,
+			wantTotal: 4,
+			wantTook:  12,
+			wantScan:  14,
+			wantPart:  true,
+		},
+		{
+			name: "multi-line data and CRLF line endings",
+			stream: "event: search_response_hits\r\ndata: {\"hits\":\r\ndata: [{\"_timestamp\":1}]}\r\n\r\n" +
+				"event: end\r\ndata: [[DONE]]\r\n\r\n",
+			wantHits: 1,
+		},
+		{
+			name:     "stream closed without end event",
+			stream:   "event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":1}]}\n\n",
+			wantHits: 1,
+		},
+		{
+			name: "error event",
+			stream: "event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":1}]}\n\n" +
+				"event: error\ndata: {\"code\":500,\"message\":\"Search stream error\",\"error_detail\":\"disk full\"}\n\n",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "error event with an application error code",
+			stream:     "event: error\ndata: {\"code\":20009,\"message\":\"Search SQL execute error\"}\n\n",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "error event with an HTTP status code",
+			stream:     "event: error\ndata: {\"code\":429,\"message\":\"too many requests\"}\n\n",
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:    "cancelled event",
+			stream:  "event: cancelled\ndata: [[CANCELLED]]\n\n",
+			wantErr: openobserve.ErrSearchCancelled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Content-Type", "text/event-stream")
+				rw.Write([]byte(tt.stream))
+			}))
+			defer srv.Close()
+
+			client := openobserve.NewOpenObserveClient(srv.URL, openobserve.ClientOptions{HTTPClient: srv.Client()})
+			param, body := newSearchParam(true)
+			resp, err := client.Search(context.Background(), param, body)
+			if tt.wantErr != nil || tt.wantStatus != 0 {
+				var apiErr *openobserve.APIError
+				switch {
+				case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+					t.Errorf("Search() error = %v, want %v", err, tt.wantErr)
+				case tt.wantStatus != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus):
+					t.Errorf("Search() error = %v, want an API error with status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Hits) != tt.wantHits {
+				t.Errorf("len(Hits) = %d, want %d", len(resp.Hits), tt.wantHits)
+			}
+			if resp.Total != tt.wantTotal || resp.Took != tt.wantTook || resp.ScanSize != tt.wantScan || resp.IsPartial != tt.wantPart {
+				t.Errorf("metadata = {Total: %d, Took: %d, ScanSize: %d, IsPartial: %t}, want {%d, %d, %d, %t}",
+					resp.Total, resp.Took, resp.ScanSize, resp.IsPartial, tt.wantTotal, tt.wantTook, tt.wantScan, tt.wantPart)
+			}
+		})
+	}
+}
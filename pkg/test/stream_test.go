@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// packetRecorder records the packets sent to a stream
+type packetRecorder struct {
+	frames []*data.Frame
+}
+
+func (r *packetRecorder) Send(packet *backend.StreamPacket) error {
+	var frame data.Frame
+	if err := json.Unmarshal(packet.Data, &frame); err != nil {
+		return err
+	}
+	r.frames = append(r.frames, &frame)
+	return nil
+}
+
+func TestRunStream_SearchStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/default/_search_stream" {
+			t.Errorf("unexpected request to %s", req.URL.Path)
+		}
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":1,\"log\":\"a\"},{\"_timestamp\":2,\"log\":\"b\"}]}\n\n" +
+			"event: progress\ndata: {\"percent\":50}\n\n" +
+			"event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":3,\"log\":\"c\"}]}\n\n" +
+			"event: end\ndata: [[DONE]]\n\n"))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, nil)
+	settings.UID = "openobserve"
+	query := map[string]any{
+		"queryType": "logs",
+		"rawSql":    "select * from log_stream",
+		"enableSSE": true,
+		"streaming": true,
+	}
+	resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("QueryData() error = %v", res.Error)
+	}
+	if len(res.Frames) != 1 || res.Frames[0].Meta == nil || !strings.HasPrefix(res.Frames[0].Meta.Channel, "ds/openobserve/search/") {
+		t.Fatalf("QueryData() frames = %v, want a single frame pointing to a search channel", res.Frames)
+	}
+	path := strings.TrimPrefix(res.Frames[0].Meta.Channel, "ds/openobserve/")
+
+	subscription, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: path})
+	if err != nil || subscription.Status != backend.SubscribeStreamStatusOK {
+		t.Fatalf("SubscribeStream() = %v, %v, want status OK", subscription, err)
+	}
+
+	recorder := &packetRecorder{}
+	if err := ds.RunStream(context.Background(), &backend.RunStreamRequest{Path: path}, backend.NewStreamSender(recorder)); err != nil {
+		t.Fatal(err)
+	}
+
+	wantRows := []int{2, 0, 1, 0} // hits, progress, hits, end
+	if len(recorder.frames) != len(wantRows) {
+		t.Fatalf("RunStream() sent %d frames, want %d", len(recorder.frames), len(wantRows))
+	}
+	for i, frame := range recorder.frames {
+		if rows, _ := frame.RowLen(); rows != wantRows[i] {
+			t.Errorf("frame %d has %d rows, want %d", i, rows, wantRows[i])
+		}
+	}
+	last := recorder.frames[len(recorder.frames)-1]
+	progress, _ := json.Marshal(last.Meta.Custom)
+	if string(progress) != `{"done":true,"hits":3,"percent":100}` {
+		t.Errorf("last frame progress = %s, want the search to be done with 3 hits", progress)
+	}
+
+	// the panel resubscribes, e.g. after a Live reconnect, and the search runs again
+	subscription, err = ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: path})
+	if err != nil || subscription.Status != backend.SubscribeStreamStatusOK {
+		t.Fatalf("SubscribeStream() after the search completed = %v, %v, want status OK", subscription, err)
+	}
+	recorder = &packetRecorder{}
+	if err := ds.RunStream(context.Background(), &backend.RunStreamRequest{Path: path}, backend.NewStreamSender(recorder)); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.frames) != len(wantRows) {
+		t.Errorf("RunStream() after resubscribing sent %d frames, want %d", len(recorder.frames), len(wantRows))
+	}
+}
+
+func TestQueryData_StreamingNotUsedByAlerting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("event: search_response_hits\ndata: {\"hits\":[{\"_timestamp\":1,\"log\":\"a\"}]}\n\nevent: end\ndata: [[DONE]]\n\n"))
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, nil)
+	req := newQueryDataRequest(settings, map[string]any{
+		"queryType": "logs",
+		"rawSql":    "select * from log_stream",
+		"enableSSE": true,
+		"streaming": true,
+	})
+	req.Headers = map[string]string{"FromAlert": "true"}
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := resp.Responses["A"]
+	if res.Error != nil {
+		t.Fatalf("QueryData() error = %v", res.Error)
+	}
+	if len(res.Frames) != 1 || res.Frames[0].Meta != nil && res.Frames[0].Meta.Channel != "" {
+		t.Fatalf("QueryData() returned a streaming frame to alerting")
+	}
+	if rows, _ := res.Frames[0].RowLen(); rows != 1 {
+		t.Errorf("QueryData() returned %d rows, want 1", rows)
+	}
+}
@@ -0,0 +1,219 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameChannel passes the frames sent to a stream to the test
+type frameChannel chan *data.Frame
+
+func (c frameChannel) Send(packet *backend.StreamPacket) error {
+	var frame data.Frame
+	if err := json.Unmarshal(packet.Data, &frame); err != nil {
+		return err
+	}
+	c <- &frame
+	return nil
+}
+
+func TestRunStream_LiveTail(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		polls int
+		start float64 // window start of the first poll
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		polls++
+		if polls == 1 {
+			start = float64(body.StartTime)
+		}
+		// the rows of the tailed stream, the second poll sees rows ingested at the boundary of the first window
+		rows := []map[string]any{{"_timestamp": start, "log": "a"}, {"_timestamp": start, "log": "b"}}
+		if polls > 1 {
+			rows = append(rows, map[string]any{"_timestamp": start, "log": "c"}, map[string]any{"_timestamp": start + 1, "log": "d"})
+		}
+		hits := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			if row["_timestamp"].(float64) >= float64(body.StartTime) {
+				hits = append(hits, row)
+			}
+		}
+		json.NewEncoder(rw).Encode(map[string]any{"hits": hits})
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"liveTailInterval": 1})
+	settings.UID = "openobserve"
+	resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, map[string]any{
+		"queryType": "logs",
+		"rawSql":    "select * from log_stream",
+		"liveTail":  true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := resp.Responses["A"].Frames[0].Meta.Channel
+	if !strings.HasPrefix(channel, "ds/openobserve/tail/") {
+		t.Fatalf("QueryData() channel = %q, want a tail channel", channel)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	frames := make(frameChannel, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- ds.RunStream(ctx, &backend.RunStreamRequest{Path: strings.TrimPrefix(channel, "ds/openobserve/")}, backend.NewStreamSender(frames))
+	}()
+
+	wantLogs := [][]string{{"a", "b"}, {"d", "c"}} // newest first, without the rows already published
+	for i, want := range wantLogs {
+		select {
+		case frame := <-frames:
+			if len(frame.Fields) != 3 || frame.Fields[1].Name != "body" {
+				t.Fatalf("frame %d is not a log frame: %v", i, frame.Fields)
+			}
+			if frame.Fields[1].Len() != len(want) {
+				t.Fatalf("frame %d has %d rows, want %d", i, frame.Fields[1].Len(), len(want))
+			}
+			for j := range want {
+				if body := frame.Fields[1].At(j).(string); !strings.Contains(body, `"log":"`+want[j]+`"`) {
+					t.Errorf("frame %d row %d = %s, want log %q", i, j, body, want[j])
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no frame %d published", i)
+		}
+	}
+
+	// nothing new on the following poll
+	select {
+	case frame := <-frames:
+		t.Errorf("unexpected frame published: %v", frame)
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("RunStream() error = %v", err)
+	}
+}
+
+// startLiveTail registers a live tail of sql and runs its stream until the test ends
+func startLiveTail(t *testing.T, url string, jsonData map[string]any, sql string) frameChannel {
+	t.Helper()
+	ds, settings := newTestDatasource(t, url, jsonData)
+	settings.UID = "openobserve"
+	resp, err := ds.QueryData(context.Background(), newQueryDataRequest(settings, map[string]any{
+		"queryType": "logs",
+		"rawSql":    sql,
+		"liveTail":  true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := resp.Responses["A"].Frames[0].Meta.Channel
+
+	ctx, cancel := context.WithCancel(context.Background())
+	frames := make(frameChannel, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ds.RunStream(ctx, &backend.RunStreamRequest{Path: strings.TrimPrefix(channel, "ds/openobserve/")}, backend.NewStreamSender(frames))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return frames
+}
+
+func TestRunStream_LiveTailRateLimit(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		start float64 // window start of the first poll
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		if start == 0 {
+			start = float64(body.StartTime)
+		}
+		// five rows ingested before the first poll, most recent first as OpenObserve returns them
+		hits := []map[string]any{}
+		for n := 5; n >= 1; n-- {
+			if timestamp := start + float64(n); timestamp >= float64(body.StartTime) && timestamp < float64(body.EndTime) {
+				hits = append(hits, map[string]any{"_timestamp": timestamp, "log": fmt.Sprint(n)})
+			}
+		}
+		hits = hits[min(body.From, int64(len(hits))):min(body.From+body.Size, int64(len(hits)))]
+		json.NewEncoder(rw).Encode(map[string]any{"hits": hits})
+	}))
+	defer srv.Close()
+
+	// two rows per poll
+	frames := startLiveTail(t, srv.URL, map[string]any{"liveTailInterval": 1, "liveTailRateLimit": 2}, "select * from log_stream")
+
+	published := map[string]int{}
+	for len(published) < 5 {
+		select {
+		case frame := <-frames:
+			if rows := frame.Fields[1].Len(); rows > 2 {
+				t.Errorf("frame has %d rows, want at most 2", rows)
+			}
+			for i := 0; i < frame.Fields[1].Len(); i++ {
+				var row map[string]any
+				json.Unmarshal([]byte(frame.Fields[1].At(i).(string)), &row)
+				published[row["log"].(string)]++
+			}
+		case <-time.After(6 * time.Second):
+			t.Fatalf("published rows = %v, want the five rows", published)
+		}
+	}
+	select {
+	case frame := <-frames:
+		t.Errorf("unexpected frame published: %v", frame)
+	case <-time.After(1500 * time.Millisecond):
+	}
+	for log, n := range published {
+		if n != 1 {
+			t.Errorf("row %s published %d times", log, n)
+		}
+	}
+}
+
+func TestRunStream_LiveTailWithoutTimestamp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(`{"hits":[{"log":"a"}]}`))
+	}))
+	defer srv.Close()
+
+	frames := startLiveTail(t, srv.URL, map[string]any{"liveTailInterval": 1}, "select log from log_stream")
+	select {
+	case frame := <-frames:
+		if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "requires the _timestamp column") {
+			t.Errorf("frame = %v, want a missing _timestamp error", frame.Meta)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the missing _timestamp was not reported")
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+)
+
+func TestQueryData_SearchTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		jsonData   map[string]any
+		query      map[string]any
+		ctxTimeout time.Duration
+		wantMin    int
+		wantMax    int
+	}{
+		{name: "datasource default", jsonData: map[string]any{}, query: map[string]any{}, wantMin: 60, wantMax: 60},
+		{name: "datasource timeout", jsonData: map[string]any{"timeout": 300}, query: map[string]any{}, wantMin: 300, wantMax: 300},
+		{name: "query override", jsonData: map[string]any{"timeout": 300}, query: map[string]any{"timeout": 5}, wantMin: 5, wantMax: 5},
+		{name: "capped by the context deadline", jsonData: map[string]any{}, query: map[string]any{"timeout": 300}, ctxTimeout: 10 * time.Second, wantMin: 9, wantMax: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTimeout int
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				var body openobserve.SearchRequestBody
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					t.Error(err)
+				}
+				gotTimeout = body.Timeout
+				rw.Write([]byte(`{"hits":[]}`))
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			if tt.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+
+			tt.query["queryType"] = "logs"
+			tt.query["rawSql"] = "select * from log_stream"
+			ds, settings := newTestDatasource(t, srv.URL, tt.jsonData)
+			resp, err := ds.QueryData(ctx, newQueryDataRequest(settings, tt.query))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := resp.Responses["A"]; res.Error != nil {
+				t.Fatalf("QueryData() error = %v", res.Error)
+			}
+			if gotTimeout < tt.wantMin || gotTimeout > tt.wantMax {
+				t.Errorf("search timeout = %d, want between %d and %d", gotTimeout, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/plugin"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func certToPEM(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// newClientCertificate generates a self-signed client certificate, returning the certificate and key as PEM
+func newClientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "grafana"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return cert, certToPEM(der), keyPEM
+}
+
+func checkHealth(t *testing.T, url string, jsonData map[string]any, secure map[string]string) *backend.CheckHealthResult {
+	jsonData["database"] = "default"
+	rawJsonData, err := json.Marshal(jsonData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance, err := plugin.NewDatasource(context.Background(), backend.DataSourceInstanceSettings{
+		URL:                     url,
+		User:                    "user",
+		JSONData:                rawJsonData,
+		DecryptedSecureJSONData: secure,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := instance.(*plugin.Datasource).CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestTLS_ServerVerification(t *testing.T) {
+	srv := httptest.NewUnstartedServer(newOpenObserveMux())
+	srv.StartTLS()
+	defer srv.Close()
+	caCert := certToPEM(srv.Certificate().Raw)
+
+	tests := []struct {
+		name     string
+		jsonData map[string]any
+		secure   map[string]string
+		status   backend.HealthStatus
+	}{
+		{
+			name:     "unknown authority is rejected by default",
+			jsonData: map[string]any{},
+			status:   backend.HealthStatusError,
+		},
+		{
+			name:     "skip verify",
+			jsonData: map[string]any{"tlsSkipVerify": true},
+			status:   backend.HealthStatusOk,
+		},
+		{
+			name:     "custom CA",
+			jsonData: map[string]any{"tlsAuthWithCACert": true},
+			secure:   map[string]string{"tlsCACert": caCert},
+			status:   backend.HealthStatusOk,
+		},
+		{
+			name:     "custom CA with server name override",
+			jsonData: map[string]any{"tlsAuthWithCACert": true, "serverName": "example.com"},
+			secure:   map[string]string{"tlsCACert": caCert},
+			status:   backend.HealthStatusOk,
+		},
+		{
+			name:     "custom CA with mismatching server name",
+			jsonData: map[string]any{"tlsAuthWithCACert": true, "serverName": "openobserve.invalid"},
+			secure:   map[string]string{"tlsCACert": caCert},
+			status:   backend.HealthStatusError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := checkHealth(t, srv.URL, tt.jsonData, tt.secure)
+			if res.Status != tt.status {
+				t.Errorf("CheckHealth() status = %v, want %v, message: %s", res.Status, tt.status, res.Message)
+			}
+		})
+	}
+}
+
+func TestTLS_ClientCertificate(t *testing.T) {
+	clientCert, clientCertPEM, clientKeyPEM := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	srv := httptest.NewUnstartedServer(newOpenObserveMux())
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	caCert := certToPEM(srv.Certificate().Raw)
+
+	res := checkHealth(t, srv.URL,
+		map[string]any{"tlsAuthWithCACert": true},
+		map[string]string{"tlsCACert": caCert},
+	)
+	if res.Status != backend.HealthStatusError {
+		t.Errorf("CheckHealth() without client certificate status = %v, want %v", res.Status, backend.HealthStatusError)
+	}
+
+	res = checkHealth(t, srv.URL,
+		map[string]any{"tlsAuthWithCACert": true, "tlsAuth": true},
+		map[string]string{"tlsCACert": caCert, "tlsClientCert": clientCertPEM, "tlsClientKey": clientKeyPEM},
+	)
+	if res.Status != backend.HealthStatusOk {
+		t.Errorf("CheckHealth() with client certificate status = %v, want %v, message: %s", res.Status, backend.HealthStatusOk, res.Message)
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LinPr/grafana-openobserve-datasource/pkg/openobserve"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQueryData_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracing.InitDefaultTracer(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test"))
+	t.Cleanup(func() { tracing.InitDefaultTracer(otel.Tracer("test")) })
+
+	traceparents := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		traceparent := req.Header.Get("traceparent")
+		traceparents <- traceparent
+		// OpenObserve derives the trace id of the search from the traceparent header
+		parts := strings.Split(traceparent, "-")
+		if len(parts) != 4 {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(rw, `{"hits":[{"_timestamp":1,"log":"a"}],"total":1,"trace_id":%q}`, parts[1])
+	}))
+	defer srv.Close()
+
+	ds, settings := newTestDatasource(t, srv.URL, map[string]any{"disableCache": true})
+	req := newQueryDataRequest(settings, map[string]any{"queryType": "logs", "rawSql": "select * from tracing_test"})
+	resp, err := ds.QueryData(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.Responses["A"].Error; err != nil {
+		t.Fatal(err)
+	}
+	traceparent := <-traceparents
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	queryData, ok := spans["QueryData"]
+	if !ok {
+		t.Fatalf("no QueryData span in %v", spans)
+	}
+	traceID := queryData.SpanContext().TraceID().String()
+	for _, name := range []string{"prepareSearchRequest", "SqlParser.ParseSql", "OpenObserveClient.Search", "OpenObserveClient.sharedSearch", "Transformer.Transform"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if got := span.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("%s span trace id = %s, want %s", name, got, traceID)
+		}
+	}
+
+	// the search has a trace id of its own, under the span which sent it
+	parts := strings.Split(traceparent, "-")
+	if want := spans["OpenObserveClient.sharedSearch"].SpanContext().SpanID().String(); parts[2] != want {
+		t.Errorf("traceparent = %q, want parent span %s", traceparent, want)
+	}
+	if parts[1] == traceID {
+		t.Errorf("traceparent = %q, want a trace id other than the Grafana one", traceparent)
+	}
+	var openobserveTraceID string
+	for _, attr := range spans["OpenObserveClient.Search"].Attributes() {
+		if attr.Key == openobserve.TraceIDAttribute {
+			openobserveTraceID = attr.Value.AsString()
+		}
+	}
+	if openobserveTraceID != parts[1] {
+		t.Errorf("%s = %q, want %s", openobserve.TraceIDAttribute, openobserveTraceID, parts[1])
+	}
+}
+
+func TestSearch_CancelSharedTrace(t *testing.T) {
+	tracing.InitDefaultTracer(sdktrace.NewTracerProvider().Tracer("test"))
+	t.Cleanup(func() { tracing.InitDefaultTracer(otel.Tracer("test")) })
+
+	var (
+		mu           sync.Mutex
+		traceIDs     = map[string]string{} // by stream
+		cancelledIDs []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/default/_search", func(rw http.ResponseWriter, req *http.Request) {
+		var body openobserve.SearchRequestBody
+		json.NewDecoder(req.Body).Decode(&body)
+		mu.Lock()
+		traceIDs[body.Sql] = strings.Split(req.Header.Get("traceparent"), "-")[1]
+		mu.Unlock()
+		select {
+		case <-req.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+			rw.Write([]byte(`{"hits":[{"_timestamp":1}]}`))
+		}
+	})
+	mux.HandleFunc("DELETE /api/default/query_manager/{traceID}", func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		cancelledIDs = append(cancelledIDs, req.PathValue("traceID"))
+		mu.Unlock()
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := openobserve.NewOpenObserveClient(srv.URL, openobserve.ClientOptions{HTTPClient: srv.Client()})
+	// both searches belong to the same Grafana request, hence to the same trace
+	ctx, span := tracing.DefaultTracer().Start(context.Background(), "QueryData")
+	defer span.End()
+
+	search := func(ctx context.Context, sql string) error {
+		param, body := newSearchParam(false)
+		body.Sql = sql
+		_, err := client.Search(ctx, param, body)
+		return err
+	}
+	cancelledCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	var cancelledErr, runningErr error
+	wg.Add(2)
+	go func() { defer wg.Done(); cancelledErr = search(cancelledCtx, "select * from cancelled") }()
+	go func() { defer wg.Done(); runningErr = search(ctx, "select * from running") }()
+	wg.Wait()
+
+	if !errors.Is(cancelledErr, context.DeadlineExceeded) {
+		t.Errorf("cancelled search error = %v, want %v", cancelledErr, context.DeadlineExceeded)
+	}
+	if runningErr != nil {
+		t.Errorf("running search error = %v", runningErr)
+	}
+	time.Sleep(50 * time.Millisecond) // the cancellation is sent asynchronously
+	mu.Lock()
+	defer mu.Unlock()
+	if traceIDs["select * from cancelled"] == traceIDs["select * from running"] {
+		t.Errorf("searches share the trace id %s", traceIDs["select * from running"])
+	}
+	if len(cancelledIDs) != 1 || cancelledIDs[0] != traceIDs["select * from cancelled"] {
+		t.Errorf("cancelled searches = %v, want only %s", cancelledIDs, traceIDs["select * from cancelled"])
+	}
+}
@@ -2,15 +2,14 @@ import React from 'react';
 import { QueryEditorProps } from '@grafana/data';
 
 import { OpenObserveDataSource } from '../datasource';
-import { OpenObserveOptions, OpenObserveQuery } from '../types';
-import { EditorMode, SqlDatasource, SqlQueryEditor } from '@grafana/plugin-ui';
+import { OpenObserveOptions, OpenObserveQuery, OpenObserveQueryFormat } from '../types';
+import { EditorMode, SQLQuery, SqlDatasource, SqlQueryEditor } from '@grafana/plugin-ui';
 import { Combobox, InlineField, ComboboxOption, Stack, InlineSwitch } from '@grafana/ui';
 
 type Props = QueryEditorProps<OpenObserveDataSource, OpenObserveQuery, OpenObserveOptions>;
 
 export function QueryEditorSQL(props: Props) {
     const queryWithDefaults: OpenObserveQuery = {
-        format: undefined, // this property actually not used
         editorMode: EditorMode.Code, // set the code editor mode as default
         ...props.query,
     };
@@ -21,6 +20,16 @@ export function QueryEditorSQL(props: Props) {
         props.onRunQuery();
     };
 
+    const onFormatChange = (option: ComboboxOption<string>) => {
+        props.onChange({ ...queryWithDefaults, outputFormat: option.value as OpenObserveQueryFormat });
+        props.onRunQuery();
+    };
+
+    const onLiveTailChange = (liveTail: boolean) => {
+        props.onChange({ ...queryWithDefaults, liveTail: liveTail });
+        props.onRunQuery();
+    };
+
     const onEnableSSEChange = (enableSSE: boolean) => {
         props.datasource.setEnableSSE(enableSSE);
         props.onChange({ ...queryWithDefaults, enableSSE: enableSSE });
@@ -42,6 +51,25 @@ export function QueryEditorSQL(props: Props) {
                     ]}
                 />
             </InlineField>
+            <InlineField label="format" tooltip="Shape of the returned data, auto returns logs for SELECT * and a table otherwise">
+                <Combobox
+                    id="query-editor-format"
+                    value={props.query.outputFormat || 'auto'}
+                    onChange={onFormatChange}
+                    options={[
+                        { label: 'auto', value: 'auto' },
+                        { label: 'logs', value: 'logs' },
+                        { label: 'table', value: 'table' },
+                        { label: 'time series', value: 'time_series' },
+                    ]}
+                />
+            </InlineField>
+            <InlineField label="liveTail" tooltip="Publish new rows as they are ingested, alerting always runs the query once">
+                <InlineSwitch
+                    value={props.query.liveTail ?? false}
+                    onChange={e => onLiveTailChange(e.currentTarget.checked)}
+                />
+            </InlineField>
             <InlineField label="enableSSE" tooltip="Enable Server-Sent Events (SSE) for real-time data streaming" grow>
                 <InlineSwitch
                     value={props.query.enableSSE ?? true}
@@ -52,7 +80,7 @@ export function QueryEditorSQL(props: Props) {
 
         <SqlQueryEditor
             {...props}
-            query={queryWithDefaults}
+            query={queryWithDefaults as SQLQuery}
             datasource={props.datasource as unknown as SqlDatasource}
         />
     </div >
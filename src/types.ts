@@ -2,20 +2,34 @@ import { AdHocVariableFilter } from '@grafana/data';
 import { SQLOptions, SQLQuery, EditorMode, QueryFormat } from '@grafana/plugin-ui';
 
 
+/**
+ * Shape of the frames returned by a query, auto returns logs for SELECT * and a table otherwise.
+ */
+export type OpenObserveQueryFormat = 'auto' | 'logs' | 'table' | 'time_series';
+
 /**
  * Represents a query specific to the OpenObserve data source.
  */
 export interface OpenObserveQuery extends SQLQuery {
-    format?: QueryFormat;
+    format?: QueryFormat; // set by the SQL editor, ignored by the backend
+    outputFormat?: OpenObserveQueryFormat;
     editorMode?: EditorMode;
     // streamType?: string;
     adhocFilters?: AdHocVariableFilter[];
     enableSSE?: boolean;
+    streaming?: boolean; // push partial SSE results through Grafana Live
+    liveTail?: boolean; // publish new rows through Grafana Live as they are ingested
+    partitioned?: boolean; // split the search by time range, automatic above the datasource partitionMinRange if unset
+    paginate?: boolean; // fetch every matching row, up to the datasource maxRows
+    bypassCache?: boolean; // skip the plugin result cache
+    incremental?: boolean; // refresh time series by only querying the new part of the time range
+    timeout?: number; // seconds, overrides the datasource timeout
 }
 
 export const DEFAULT_QUERY: Partial<OpenObserveQuery> = {
     queryType: "logs",
-    enableSSE: true
+    enableSSE: true,
+    outputFormat: 'auto'
 };
 
 /**
@@ -23,6 +37,30 @@ export const DEFAULT_QUERY: Partial<OpenObserveQuery> = {
  */
 export interface OpenObserveOptions extends SQLOptions {
     url: string
+    authMode?: 'basic' | 'bearer' | 'header';
+    tlsAuth?: boolean;
+    tlsAuthWithCACert?: boolean;
+    tlsSkipVerify?: boolean;
+    serverName?: string;
+    oauthPassThru?: boolean;
+    keepCookies?: string[]; // cookies forwarded to OpenObserve
+    retryMaxAttempts?: number;
+    timeout?: number; // seconds
+    liveTailInterval?: number; // seconds
+    liveTailRateLimit?: number; // rows per second
+    partitionMinRange?: number; // seconds
+    partitionConcurrency?: number;
+    maxRows?: number; // row ceiling of a query
+    disableCache?: boolean;
+    cacheTTL?: number; // seconds
+    cacheMaxSize?: number; // megabytes of decoded hits
+    incrementalOverlap?: number; // seconds
+    maxConcurrentQueries?: number; // requests in flight to OpenObserve, negative for unlimited
+    queryTypeConcurrency?: Record<string, number>; // by query type: logs, metrics, traces or fallback
+    disableCircuitBreaker?: boolean;
+    circuitBreakerFailures?: number; // consecutive failures suspending requests
+    circuitBreakerOpenDuration?: number; // seconds
+    serveStaleResults?: boolean; // serve the last result of a query while requests are suspended
 }
 
 /**
@@ -30,6 +68,10 @@ export interface OpenObserveOptions extends SQLOptions {
  */
 export interface OpenObserveSecureJsonData {
     password?: string;
+    token?: string;
+    tlsCACert?: string;
+    tlsClientCert?: string;
+    tlsClientKey?: string;
 }
 
 /**